@@ -1,12 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/imagvfx/forge"
 )
@@ -103,6 +106,42 @@ func getThumbnail(host, session, path string) (*forge.Thumbnail, error) {
 	return thumb, err
 }
 
+// getThumbnailIfChanged downloads thumbnail of an entry, only when it's checksum is different from sum.
+// sum is base64url encoded md5 checksum of the thumbnail, that forge uses as ETag.
+// It returns false without data when the thumbnail isn't changed.
+func getThumbnailIfChanged(host, session, path, sum string) ([]byte, bool, error) {
+	if session == "" {
+		return nil, false, fmt.Errorf("login please")
+	}
+	u := url.URL{Scheme: "https", Host: host, Path: "/thumbnail" + path}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.AddCookie(&http.Cookie{Name: "session", Value: session})
+	req.Header.Set("If-None-Match", sum)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("get thumbnail: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	// forge redirects to the login page when the session is expired.
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return nil, false, fmt.Errorf("get thumbnail: unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+	return b, true, nil
+}
+
 func getBaseEntryTypes(host, session string) ([]string, error) {
 	if session == "" {
 		return nil, fmt.Errorf("login please")
@@ -333,3 +372,143 @@ func entryEnvirons(host, session, path string) ([]*forge.Property, error) {
 	}
 	return forgeEnv, nil
 }
+
+func getEnviron(host, session, path, name string) (*forge.Property, error) {
+	if session == "" {
+		return nil, fmt.Errorf("login please")
+	}
+	resp, err := http.PostForm("https://"+host+"/api/get-environ", url.Values{
+		"session": {session},
+		"path":    {path},
+		"name":    {name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var e *forge.Property
+	err = decodeAPIResponse(resp, &e)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func updateProperty(host, session, path, name, value string) error {
+	if session == "" {
+		return fmt.Errorf("login please")
+	}
+	resp, err := http.PostForm("https://"+host+"/api/update-property", url.Values{
+		"session": {session},
+		"path":    {path},
+		"name":    {name},
+		"value":   {value},
+	})
+	if err != nil {
+		return err
+	}
+	err = decodeAPIResponse(resp, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func getProperty(host, session, path, name string) (*forge.Property, error) {
+	if session == "" {
+		return nil, fmt.Errorf("login please")
+	}
+	resp, err := http.PostForm("https://"+host+"/api/get-property", url.Values{
+		"session": {session},
+		"path":    {path},
+		"name":    {name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var p *forge.Property
+	err = decodeAPIResponse(resp, &p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func addThumbnail(host, session, path string, img []byte) error {
+	return postThumbnail(host, session, "add-thumbnail", path, img)
+}
+
+func updateThumbnail(host, session, path string, img []byte) error {
+	return postThumbnail(host, session, "update-thumbnail", path, img)
+}
+
+// postThumbnail posts an image to a thumbnail api as a multipart form.
+func postThumbnail(host, session, api, path string, img []byte) error {
+	if session == "" {
+		return fmt.Errorf("login please")
+	}
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	w.WriteField("session", session)
+	w.WriteField("path", path)
+	fw, err := w.CreateFormFile("file", "thumbnail.png")
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(img)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post("https://"+host+"/api/"+api, w.FormDataContentType(), body)
+	if err != nil {
+		return err
+	}
+	err = decodeAPIResponse(resp, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func addEntry(host, session, path, typ string) error {
+	if session == "" {
+		return fmt.Errorf("login please")
+	}
+	resp, err := http.PostForm("https://"+host+"/api/add-entry", url.Values{
+		"session": {session},
+		"path":    {path},
+		"type":    {typ},
+	})
+	if err != nil {
+		return err
+	}
+	err = decodeAPIResponse(resp, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func addEnviron(host, session, path, name, typ, value string) error {
+	if session == "" {
+		return fmt.Errorf("login please")
+	}
+	resp, err := http.PostForm("https://"+host+"/api/add-environ", url.Values{
+		"session": {session},
+		"path":    {path},
+		"name":    {name},
+		"type":    {typ},
+		"value":   {value},
+	})
+	if err != nil {
+		return err
+	}
+	err = decodeAPIResponse(resp, nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}
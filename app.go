@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/big"
 	"os"
@@ -17,6 +18,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/imagvfx/forge"
 	wails "github.com/wailsapp/wails/v2/pkg/runtime"
@@ -40,16 +42,30 @@ type App struct {
 	program map[string]*Program
 	state   *State
 	// hold cacheLock before modify cachedEnvs
-	cacheLock     sync.Mutex
-	cachedEnvs    map[string][]string
-	globalLock    sync.Mutex
-	global        map[string]map[string]*forge.Global
-	thumbnail     map[string]*forge.Thumbnail
+	cacheLock   sync.Mutex
+	cachedEnvs  map[string][]string
+	globalLock  sync.Mutex
+	global      map[string]map[string]*forge.Global
+	thumbCache  *thumbCache
+	thumbFlight flightGroup
+	// hold thumbnailLock before modify hasThumbnail or thumbnailOwner
 	thumbnailLock sync.Mutex
-	history       []string
-	historyIdx    int
-	assigned      []*forge.Entry
-	entrySorters  map[string]Sorter
+	// hasThumbnail remembers whether entries have their own thumbnails.
+	hasThumbnail map[string]bool
+	// thumbnailOwner maps an entry path to the entry path which has the thumbnail.
+	thumbnailOwner map[string]string
+	history        []string
+	historyIdx     int
+	assigned       []*forge.Entry
+	entrySorters   map[string]Sorter
+	// hold watcherLock before modify watcher
+	watcherLock sync.Mutex
+	watcher     *sceneWatcher
+	// hold diskUsageLock before modify diskUsage
+	diskUsageLock   sync.Mutex
+	diskUsage       map[string]*DiskUsage
+	diskUsageFlight flightGroup
+	checksums       checksumCache
 }
 
 // NewApp creates a new App application struct
@@ -58,12 +74,14 @@ func NewApp(cfg *Config) *App {
 	for _, pg := range cfg.Programs {
 		program[pg.Name] = pg
 	}
-	thumbnail := make(map[string]*forge.Thumbnail)
 	return &App{
-		config:    cfg,
-		host:      cfg.Host,
-		program:   program,
-		thumbnail: thumbnail,
+		config:         cfg,
+		host:           cfg.Host,
+		program:        program,
+		thumbCache:     openThumbCache(cfg),
+		hasThumbnail:   make(map[string]bool),
+		thumbnailOwner: make(map[string]string),
+		diskUsage:      make(map[string]*DiskUsage),
 	}
 }
 
@@ -73,6 +91,14 @@ func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 }
 
+// shutdown is called when the app is closing.
+func (a *App) shutdown(ctx context.Context) {
+	err := a.thumbCache.Flush()
+	if err != nil {
+		log.Printf("save thumbnail cache: %v", err)
+	}
+}
+
 // Prepare prepares start up of the app gui.
 // It is similar to startup, but I need separate method for functions
 // those return error.
@@ -144,52 +170,10 @@ func (a *App) GetEntry(path string) (*forge.Entry, error) {
 	if err != nil {
 		return nil, err
 	}
+	a.noteThumbnails(ent)
 	return ent, nil
 }
 
-func (a *App) GetThumbnail(path string) (*forge.Thumbnail, error) {
-	a.thumbnailLock.Lock()
-	defer a.thumbnailLock.Unlock()
-	thumb := a.thumbnail[path]
-	if thumb != nil {
-		return thumb, nil
-	}
-	var thumbEnt *forge.Entry
-	ent, err := a.GetEntry(path)
-	if err != nil {
-		return nil, err
-	}
-	if ent.HasThumbnail {
-		thumbEnt = ent
-	} else {
-		parents, err := a.ParentEntries(path)
-		if err != nil {
-			return nil, err
-		}
-		for i := len(parents) - 1; i >= 0; i-- {
-			ent = parents[i]
-			if ent.HasThumbnail {
-				thumbEnt = ent
-				break
-			}
-		}
-	}
-	if thumbEnt == nil {
-		return nil, fmt.Errorf("couldn't find thumbnail: %v", path)
-	}
-	thumb = a.thumbnail[thumbEnt.Path]
-	if thumb != nil {
-		return thumb, nil
-	}
-	thumb, err = getThumbnail(a.host, a.session, thumbEnt.Path)
-	if err != nil {
-		return nil, err
-	}
-	a.thumbnail[thumbEnt.Path] = thumb
-	a.thumbnail[path] = thumb
-	return thumb, nil
-}
-
 func (a *App) ReloadGlobals() error {
 	types, err := getBaseEntryTypes(a.host, a.session)
 	if err != nil {
@@ -284,6 +268,8 @@ type State struct {
 	AtLeaf            bool
 	Entries           []*forge.Entry
 	Elements          []*Elem
+	Unmanaged         []*Unmanaged
+	Outputs           []*Output
 	Entry             *forge.Entry
 	ParentEntries     []*forge.Entry
 	Dir               string
@@ -306,6 +292,8 @@ func (a *App) newState() *State {
 		RecentPaths:       make([]string, 0),
 		Entries:           make([]*forge.Entry, 0),
 		Elements:          make([]*Elem, 0),
+		Unmanaged:         make([]*Unmanaged, 0),
+		Outputs:           make([]*Output, 0),
 		ParentEntries:     make([]*forge.Entry, 0),
 		ExposedProperties: make(map[string][]string),
 	}
@@ -402,6 +390,20 @@ func (a *App) SetAssignedOnly(only bool) error {
 	return nil
 }
 
+// SetSortVersionsByTime set sortVersionsByTime option enabled/disabled.
+func (a *App) SetSortVersionsByTime(byTime bool) error {
+	a.state.Options.SortVersionsByTime = byTime
+	value, err := json.Marshal(byTime)
+	if err != nil {
+		return err
+	}
+	err = setUserData(a.host, a.session, a.user, "options.sort_versions_by_time", string(value))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 // ListEntries shows sub entries of an entry,
 // it shows only paths to assigned entries when the options is enabled.
 func (a *App) ListEntries(path string) ([]*forge.Entry, error) {
@@ -434,6 +436,7 @@ func (a *App) ListAllEntries(path string) ([]*forge.Entry, error) {
 	if err != nil {
 		return nil, err
 	}
+	a.noteThumbnails(ents...)
 	sort.Slice(ents, func(i, j int) bool {
 		cmp := strings.Compare(ents[i].Type, ents[j].Type)
 		if cmp != 0 {
@@ -553,6 +556,7 @@ func (a *App) ParentEntries(path string) ([]*forge.Entry, error) {
 	if err != nil {
 		return nil, err
 	}
+	a.noteThumbnails(parents...)
 	return parents, nil
 }
 
@@ -663,18 +667,88 @@ func (a *App) loadEntry(entry *forge.Entry) error {
 	}
 	a.state.Entries = []*forge.Entry{}
 	a.state.Elements = []*Elem{}
+	a.state.Unmanaged = []*Unmanaged{}
+	a.state.Outputs = []*Output{}
 	// we only can have either entries or elements by design.
 	if a.state.AtLeaf {
-		a.state.Elements, err = a.ListElements(path)
+		a.state.Elements, a.state.Unmanaged, err = a.listElements(path)
+		if err != nil {
+			return err
+		}
+		outputs, err := a.ListOutputs(path)
+		if err != nil {
+			// elements are still usable without outputs.
+			log.Printf("list outputs: %v", err)
+			outputs = []*Output{}
+		}
+		a.state.Outputs = outputs
 	} else {
 		a.state.Entries, err = a.ListEntries(path)
+		if err != nil {
+			return err
+		}
 	}
+	thumbPaths := []string{path}
+	for _, e := range a.state.Entries {
+		thumbPaths = append(thumbPaths, e.Path)
+	}
+	go a.prefetchThumbnails(thumbPaths)
+	if _, ok := a.config.Dir[entry.Type]; ok && a.config.AutoDirTemplate {
+		_, err := a.ApplyDirTemplate(path)
+		if err != nil {
+			// the entry is still usable.
+			log.Printf("apply directory template: %v", err)
+		}
+	}
+	err = a.watchElements(path)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// watchElements watches scene directory of the current entry, if it is a leaf entry.
+// It emits "elementsChanged" event with the path when files in the directory are changed,
+// then the frontend reloads the entry.
+// Watching of the previous entry will be stopped.
+func (a *App) watchElements(path string) error {
+	a.watcherLock.Lock()
+	defer a.watcherLock.Unlock()
+	if a.watcher != nil {
+		a.watcher.Close()
+		a.watcher = nil
+	}
+	if !a.state.AtLeaf {
+		return nil
+	}
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return err
+	}
+	sceneDir := getEnv("SCENE_DIR", env)
+	if sceneDir == "" {
+		return nil
+	}
+	sceneDir = evalEnvString(sceneDir, env)
+	// WATCH_POLL_INTERVAL makes the app poll the directory, where fsnotify doesn't work. (ex: NFS, SMB)
+	var poll time.Duration
+	if v := getEnv("WATCH_POLL_INTERVAL", env); v != "" {
+		poll, err = time.ParseDuration(v)
+		if err != nil || poll < 0 {
+			// the entry is still usable, fall back to fsnotify.
+			log.Printf("invalid WATCH_POLL_INTERVAL environ %q: falling back to fsnotify", v)
+			poll = 0
+		}
+	}
+	depth := sceneDepth(env)
+	// the callback runs on the watcher's goroutine, it shouldn't touch a.state.
+	// the frontend reloads the entry when it is still showing the path.
+	a.watcher = watchSceneDir(sceneDir, depth, poll, func() {
+		wails.EventsEmit(a.ctx, "elementsChanged", path)
+	})
+	return nil
+}
+
 // OpenLoginPage shows login page to user.
 func (a *App) OpenLoginPage(key string) error {
 	return openPath("https://" + a.host + "/login?app_session_key=" + key)
@@ -729,6 +803,8 @@ func (a *App) removeSession() error {
 // Note that options those are closely related with the user will remembered by host instead.
 type Options struct {
 	AssignedOnly bool
+	// SortVersionsByTime sorts versions of elements by their modification time instead of numbers.
+	SortVersionsByTime bool
 }
 
 func (a *App) ReloadUserData() error {
@@ -741,6 +817,11 @@ func (a *App) ReloadUserData() error {
 		// Empty or invalid data. Set the default value.
 		a.state.Options.AssignedOnly = false
 	}
+	err = json.Unmarshal([]byte(sec.Data["options.sort_versions_by_time"]), &a.state.Options.SortVersionsByTime)
+	if err != nil {
+		// Empty or invalid data. Set the default value.
+		a.state.Options.SortVersionsByTime = false
+	}
 	a.state.ExposedProperties = make(map[string][]string)
 	for key, data := range sec.Data {
 		_, entType, found := strings.Cut(key, "exposed_properties.")
@@ -806,6 +887,12 @@ func (a *App) addRecentPath(path string) error {
 
 // Logout forgets session info of latest logged in user.
 func (a *App) Logout() error {
+	a.watcherLock.Lock()
+	if a.watcher != nil {
+		a.watcher.Close()
+		a.watcher = nil
+	}
+	a.watcherLock.Unlock()
 	a.assigned = nil
 	a.state = a.newState()
 	err := a.removeSession()
@@ -836,6 +923,22 @@ type Program struct {
 	Ext       string
 	CreateCmd []string
 	OpenCmd   []string
+	// Wrapper overrides Config.Wrapper for the program.
+	Wrapper []string
+	// Magic is the bytes every scene of the program starts with.
+	// It is used to check a scene is not broken, if specified. (ex: "BLENDER")
+	Magic string
+	// DirScene indicates that scenes of the program are directories, instead of files.
+	// (ex: project folders of some editors)
+	DirScene bool
+	// NoLock disables locking of scenes opened by the program.
+	// Locks are released when the launched process exits, set it when OpenCmd exits
+	// right after starting the program. (ex: open -a Blender)
+	NoLock bool
+	// Templates are scene file templates for new elements, keyed by element name.
+	// A key could be prefixed with a part name to be used only for the part. (ex: lgt/light, lgt/*)
+	// Template of "*" will be used for elements those don't have their own.
+	Templates map[string]string
 }
 
 // Program returns a Program of given name.
@@ -968,144 +1071,362 @@ func evalEnvString(v string, env []string) string {
 	return v
 }
 
+// envRefs returns names of environment variables referenced in a string.
+func envRefs(v string) []string {
+	re := regexp.MustCompile(`[$][{]?(\w+)[}]?`)
+	refs := make([]string, 0)
+	for _, m := range re.FindAllStringSubmatch(v, -1) {
+		refs = append(refs, m[1])
+	}
+	return refs
+}
+
+// wrapCmd prepends a wrapper command, like a package manager's, to cmd.
+// The wrapper is used only when every environ it references is defined in env,
+// so entries without those environs launch the command directly.
+// Each evaluated wrapper argument is split by whitespace, to let an environ hold
+// multiple arguments. (ex: REZ_PACKAGES="houdini-19.5 show_tools")
+func wrapCmd(cmd, wrapper, env []string) []string {
+	if len(wrapper) == 0 {
+		return cmd
+	}
+	wrap := make([]string, 0, len(wrapper)+len(cmd))
+	for _, w := range wrapper {
+		for _, ref := range envRefs(w) {
+			if getEnv(ref, env) == "" {
+				return cmd
+			}
+		}
+		wrap = append(wrap, strings.Fields(evalEnvString(w, env))...)
+	}
+	return append(wrap, cmd...)
+}
+
+// programCmd evaluates a command template of a program with env,
+// and wraps it with the program's wrapper if needed.
+func (a *App) programCmd(pg *Program, tmpl, env []string) ([]string, error) {
+	cmd := make([]string, 0, len(tmpl))
+	for _, c := range tmpl {
+		c = evalEnvString(c, env)
+		c = strings.TrimSpace(c)
+		if c != "" {
+			cmd = append(cmd, c)
+		}
+	}
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("command not specified for program: %s", pg.Name)
+	}
+	wrapper := a.config.Wrapper
+	if pg.Wrapper != nil {
+		wrapper = pg.Wrapper
+	}
+	return wrapCmd(cmd, wrapper, env), nil
+}
+
 // EntryEnvirons gets environs from an entry.
 func (a *App) EntryEnvirons(path string) ([]string, error) {
-	// check cached environs first to make only one query per path.
-	// The cache is remained until user reloaded or moved to other entry.
+	userEnv, err := a.userEnvirons()
+	if err != nil {
+		return nil, err
+	}
+	return a.entryEnvironsWith(path, userEnv)
+}
+
+// userEnvirons returns environs the user defined in the "environ" user data section.
+// Callers those need environs of many entries should get it only once, and use entryEnvironsWith.
+func (a *App) userEnvirons() (map[string]string, error) {
+	sec, err := getUserDataSection(a.host, a.session, a.user, "environ")
+	if err != nil {
+		// TODO: shouldn't rely on error messages.
+		if err.Error() != "user data section is not exists: environ" {
+			return nil, err
+		}
+	}
+	if sec == nil {
+		return map[string]string{}, nil
+	}
+	return sec.Data, nil
+}
+
+// entryEnvironsWith is EntryEnvirons with user environs those are already got.
+func (a *App) entryEnvironsWith(path string, userEnv map[string]string) ([]string, error) {
 	env := os.Environ()
 	forgeEnv, err := entryEnvirons(a.host, a.session, path)
 	if err != nil {
 		return nil, err
 	}
+	// path environs for another os are mapped to this os's.
 	for _, e := range forgeEnv {
-		env = setEnv(e.Name, e.Eval, env)
+		if isRecordEnviron(e.Name) {
+			// notes and publishes are kept in environs, but they are not for programs.
+			continue
+		}
+		env = setEnv(e.Name, a.resolveEnv(e.Name, e.Eval), env)
 	}
 	for _, e := range a.config.Envs {
 		kv := strings.SplitN(e, "=", 2)
-		env = setEnv(kv[0], kv[1], env)
+		env = setEnv(kv[0], a.resolveEnv(kv[0], kv[1]), env)
 	}
-	sec, err := getUserDataSection(a.host, a.session, a.user, "environ")
+	for key, val := range userEnv {
+		env = setEnv(key, a.resolveEnv(key, val), env)
+	}
+	return env, nil
+}
+
+// NewElement creates a new element by creating a scene file.
+// When the program has a template for the element, the template is copied as the scene file,
+// and the program will be launched only if launch is true.
+// Otherwise, the program's CreateCmd is responsible for creating the scene file.
+// The note will be saved in the scene's meta, if it isn't empty.
+func (a *App) NewElement(path, name, prog, note string, launch bool) error {
+	pg := a.Program(prog)
+	if pg == nil {
+		return fmt.Errorf("unknown program: %s", prog)
+	}
+	res, env, err := a.nextVersionScene(path, name, pg)
 	if err != nil {
-		// TODO: shouldn't rely on error messages.
-		if err.Error() != "user data section is not exists: environ" {
-			return nil, err
+		return err
+	}
+	// the reservation is kept while CreateCmd runs, which lasts until the user quits the program.
+	defer res.Release()
+	scene := res.scene
+	sceneDir := filepath.Dir(scene)
+	// meta should be written after the scene is created, or it will be left alone when creation failed.
+	meta := &SceneMeta{
+		CreatedBy: a.user,
+		CreatedAt: time.Now(),
+		Note:      note,
+	}
+	tmpl, err := a.sceneTemplate(pg, path, name, env)
+	if err != nil {
+		return err
+	}
+	if tmpl != "" {
+		err = copyScene(tmpl, scene)
+		if err != nil {
+			return fmt.Errorf("copy template: %v", err)
+		}
+		err = writeSceneMeta(scene, meta)
+		if err != nil {
+			return fmt.Errorf("element created, but writing meta failed: %v", err)
 		}
+		if launch {
+			return a.OpenScene(path, name, getEnv("VER", env), prog)
+		}
+		return a.addRecentPath(path)
+	}
+	if !launch {
+		return fmt.Errorf("cannot create element without launching %s: no template for the element", prog)
+	}
+	createCmd, err := a.programCmd(pg, pg.CreateCmd, env)
+	if err != nil {
+		return err
 	}
-	if sec != nil {
-		for key, val := range sec.Data {
-			env = setEnv(key, val, env)
+	cmd := exec.Command(createCmd[0], createCmd[1:]...)
+	cmd.Dir = sceneDir
+	cmd.Env = env
+	b, err := cmd.CombinedOutput()
+	out := string(b)
+	fmt.Println(out)
+	if err != nil {
+		fmt.Println(err)
+	}
+	// the program creates the scene only when the user saved it.
+	_, err = os.Stat(scene)
+	if err == nil {
+		err = writeSceneMeta(scene, meta)
+		if err != nil {
+			return fmt.Errorf("element created, but writing meta failed: %v", err)
 		}
 	}
-	return env, nil
+	err = a.addRecentPath(path)
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
-// NewElement creates a new element by creating a scene file.
-func (a *App) NewElement(path, name, prog string) error {
+// VersionUp copies a version of an element to the next version, and launches it if needed.
+// It remembers who versioned up the scene from which version in the scene's meta, with the note.
+func (a *App) VersionUp(path, elem, fromVer, prog, note string, launch bool) (string, error) {
+	return a.CopyElement(path, elem, fromVer, prog, path, elem, note, launch)
+}
+
+// CopyElement copies a version of an element to the next version of an element of another part.
+// The destination scene is named by the destination part's environs, and launched if needed.
+// It returns the version of the copied scene.
+func (a *App) CopyElement(srcPath, elem, ver, prog, dstPath, newElem, note string, launch bool) (string, error) {
+	pg := a.Program(prog)
+	if pg == nil {
+		return "", fmt.Errorf("unknown program: %s", prog)
+	}
+	dst, err := a.GetEntry(dstPath)
+	if err != nil {
+		return "", err
+	}
+	if dst.Type != a.config.LeafEntryType {
+		return "", fmt.Errorf("cannot copy element to %s entry: %s", dst.Type, dstPath)
+	}
+	from, err := a.SceneFile(srcPath, elem, ver, prog)
+	if err != nil {
+		return "", err
+	}
+	_, err = os.Stat(from)
+	if err != nil {
+		return "", err
+	}
+	res, env, err := a.nextVersionScene(dstPath, newElem, pg)
+	if err != nil {
+		return "", err
+	}
+	defer res.Release()
+	scene := res.scene
+	err = copyScene(from, scene)
+	if err != nil {
+		return "", fmt.Errorf("copy scene: %v", err)
+	}
+	newVer := getEnv("VER", env)
+	err = writeSceneMeta(scene, &SceneMeta{
+		CreatedBy: a.user,
+		CreatedAt: time.Now(),
+		From:      from,
+		Note:      note,
+	})
+	if err != nil {
+		return "", err
+	}
+	if launch {
+		err = a.OpenScene(dstPath, newElem, newVer, prog)
+		if err != nil {
+			return "", err
+		}
+		return newVer, nil
+	}
+	err = a.addRecentPath(dstPath)
+	if err != nil {
+		return "", err
+	}
+	return newVer, nil
+}
+
+// nextVersionScene finds the next version of an element, and returns reservation of it's scene path
+// with environs for the version, including ELEM, VER, EXT and SCENE.
+// It also creates the scene directory if it doesn't exist yet.
+// The caller should Release the reservation after the scene is created.
+func (a *App) nextVersionScene(path, elem string, pg *Program) (*sceneReservation, []string, error) {
 	env, err := a.EntryEnvirons(path)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	sceneDir := getEnv("SCENE_DIR", env)
 	if sceneDir == "" {
-		return fmt.Errorf("no scene directory information: check SCENE_DIR environ")
+		return nil, nil, fmt.Errorf("no scene directory information: check SCENE_DIR environ")
 	}
 	sceneDir = evalEnvString(sceneDir, env)
 	err = os.MkdirAll(sceneDir, 0755)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	sceneNameEnv := "SCENE_NAME"
-	if name == "" {
+	if elem == "" {
 		sceneNameEnv = "MAIN_SCENE_NAME"
 	}
 	sceneName := getEnv(sceneNameEnv, env)
 	if sceneName == "" {
-		return fmt.Errorf("no scene name information: check " + sceneNameEnv + " environ")
+		return nil, nil, fmt.Errorf("no scene name information: check " + sceneNameEnv + " environ")
 	}
-	pg := a.Program(prog)
-	if pg == nil {
-		return fmt.Errorf("unknown program: %s", prog)
-	}
-	env = append(env, "ELEM="+name)
+	env = append(env, "ELEM="+elem)
 	env = append(env, "EXT="+pg.Ext)
 	env = append(env, "FORGE_SESSION="+a.session)
 	// find lastest version of the element, and increment 1 from it.
-	var scene string
-	verPre := "v"
-	verDigits := "001"
-	// override verPre, verDigits if NEW_VER environ defined.
-	ver := getEnv("NEW_VER", env)
-	if ver != "" {
-		for i := len(ver) - 1; i >= 0; i-- {
-			_, err := strconv.Atoi(string(ver[i]))
-			if err != nil {
-				verPre = ver[:i+1]
-				verDigits = ver[i+1:]
-			}
-		}
+	scheme, err := verSchemeOf(env)
+	if err != nil {
+		return nil, nil, err
 	}
-	nDigits := len(verDigits)
-	start, _ := strconv.Atoi(verDigits)
-	last, err := a.LastVersionOfElement(path, name, prog)
+	start := scheme.Start
+	last, err := a.LastVersionOfElement(path, elem, pg.Name)
 	if err != nil {
 		e := &ElemNotExistError{}
 		if !errors.As(err, &e) {
-			return err
+			return nil, nil, err
 		}
 	}
 	if last != "" {
-		last = strings.TrimPrefix(last, "v")
-		n, err := strconv.Atoi(last)
+		n, err := scheme.Parse(last)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		start = n + 1
 	}
+	res, env, err := reserveNextScene(sceneDir, sceneName, scheme, start, env, a.user)
+	if err != nil {
+		return nil, nil, err
+	}
+	env = append(env, "SCENE="+res.scene)
+	return res, env, nil
+}
+
+// reserveNextScene reserves the first scene path in the scene directory from start version,
+// that neither exists nor is reserved. It returns the reservation with environs having VER of it.
+func reserveNextScene(sceneDir, sceneName string, scheme VerScheme, start int, env []string, user string) (*sceneReservation, []string, error) {
 	for n := start; ; n++ {
-		v := strconv.Itoa(n)
-		z := nDigits - len(v)
-		if z < 0 {
-			z = 0
-		}
-		ver := verPre + strings.Repeat("0", z) + v
+		ver := scheme.Format(n)
 		env = setEnv("VER", ver, env)
 		name := evalEnvString(sceneName, env)
-		scene = sceneDir + "/" + name
-		_, err := os.Stat(scene)
+		if name == "" {
+			return nil, nil, fmt.Errorf("couldn't get appropriate scene name: %s", sceneName)
+		}
+		scene := sceneDir + "/" + name
+		// scene name could have sub directories.
+		err := os.MkdirAll(filepath.Dir(scene), 0755)
 		if err != nil {
-			if !errors.Is(err, os.ErrNotExist) {
-				return err
+			return nil, nil, err
+		}
+		res, err := reserveScene(scene, user)
+		if err != nil {
+			if !errors.Is(err, os.ErrExist) {
+				return nil, nil, fmt.Errorf("reserve scene: %v", err)
 			}
-			// found the first scene path that is not exists.
+			// taken by an existing scene or someone else. try next.
+			continue
+		}
+		return res, env, nil
+	}
+}
+
+// sceneTemplate returns a template file path for an element of a program.
+// Templates of a program are looked up in order of "part/elem", "part/*", "elem" and "*".
+// The part is name of the entry.
+// The template path is evaluated with env, so shows can override it with their environs.
+// It returns an empty string if the program doesn't have a template for the element,
+// or the template file doesn't exist.
+func (a *App) sceneTemplate(pg *Program, path, elem string, env []string) (string, error) {
+	part := filepath.Base(path)
+	var tmpl string
+	found := false
+	for _, key := range []string{part + "/" + elem, part + "/*", elem, "*"} {
+		tmpl, found = pg.Templates[key]
+		if found {
 			break
 		}
 	}
-	if scene == "" {
-		return fmt.Errorf("couldn't get appropriate scene name:", sceneName)
-	}
-	env = append(env, "SCENE="+scene)
-	createCmd := make([]string, 0, len(pg.CreateCmd))
-	for _, c := range pg.CreateCmd {
-		c = evalEnvString(c, env)
-		c = strings.TrimSpace(c)
-		if c != "" {
-			createCmd = append(createCmd, c)
-		}
+	if !found {
+		return "", nil
 	}
-	cmd := exec.Command(createCmd[0], createCmd[1:]...)
-	cmd.Dir = sceneDir
-	cmd.Env = env
-	b, err := cmd.CombinedOutput()
-	out := string(b)
-	fmt.Println(out)
-	if err != nil {
-		fmt.Println(err)
+	tmpl = a.ResolvePath(evalEnvString(tmpl, env))
+	if tmpl == "" {
+		return "", nil
 	}
-	err = a.addRecentPath(path)
+	_, err := os.Stat(tmpl)
 	if err != nil {
-		return err
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("template: %v", err)
+		}
+		// the template might not be prepared for the show yet.
+		log.Printf("template not exists, create %s without template: %s", pg.Name, tmpl)
+		return "", nil
 	}
-	return nil
+	return tmpl, nil
 }
 
 // Elem is an element of a part.
@@ -1119,86 +1440,231 @@ type Elem struct {
 // Version is a version of an element.
 // Version in the app represents a file in a part directory that is in an element group.
 type Version struct {
-	Name  string
-	Num   int
-	Scene string
+	Name    string
+	Num     int
+	Scene   string
+	ModTime time.Time
+	Size    int64
+	// Owner is the user who owns the scene file.
+	// It falls back to the user who created the scene through the app, when unknown.
+	Owner string
+	// Note is a note of the version, saved in the scene's meta.
+	Note string
+	// Lock tells who has opened the version, if any.
+	Lock *SceneLock
+	// Checksum is the checksum of the scene, if computed.
+	Checksum string
+	// Problems are integrity problems found in the scene. (ex: empty file)
+	Problems []string
+	// Published indicates the version is published.
+	Published bool
+	// meta is the scene's meta, or nil if it doesn't have one.
+	meta *SceneMeta
+}
+
+// statVersion fills file information of the version's scene.
+func statVersion(v *Version) error {
+	fi, err := os.Stat(v.Scene)
+	if err != nil {
+		return err
+	}
+	v.ModTime = fi.ModTime()
+	v.Size = fi.Size()
+	v.Owner = fileOwner(fi)
+	meta, err := readSceneMeta(v.Scene)
+	if err != nil {
+		// meta is additional information, the version is still usable without it.
+		log.Printf("read scene meta: %v", err)
+		meta = nil
+	}
+	v.meta = meta
+	if meta != nil {
+		if v.Owner == "" {
+			v.Owner = meta.CreatedBy
+		}
+		v.Note = meta.Note
+		v.Published = !meta.PublishedAt.IsZero()
+	}
+	v.Lock, err = activeSceneLock(v.Scene)
+	if err != nil {
+		// the lock is only for information.
+		log.Printf("read scene lock: %v", err)
+		v.Lock = nil
+	}
+	return nil
+}
+
+// sortVersions sorts versions from the latest to the oldest.
+// Versions are sorted by their numbers, or by modification time when byTime is true.
+func sortVersions(vers []Version, byTime bool) {
+	sort.Slice(vers, func(i, j int) bool {
+		if byTime && !vers[i].ModTime.Equal(vers[j].ModTime) {
+			return vers[i].ModTime.After(vers[j].ModTime)
+		}
+		cmp := vers[i].Num - vers[j].Num
+		if cmp != 0 {
+			return cmp > 0
+		}
+		// prefer version having more digits
+		return len(vers[i].Name) > len(vers[j].Name)
+	})
 }
 
 // ListElements returns elements of a part entry each of which holds versions as well.
 func (a *App) ListElements(path string) ([]*Elem, error) {
-	env, err := a.EntryEnvirons(path)
+	elems, _, err := a.listElements(path)
 	if err != nil {
 		return nil, err
 	}
+	return elems, nil
+}
+
+// ListUnmanaged returns files of a part entry those look like scenes, but not managed by the app.
+func (a *App) ListUnmanaged(path string) ([]*Unmanaged, error) {
+	_, unmanaged, err := a.listElements(path)
+	if err != nil {
+		return nil, err
+	}
+	return unmanaged, nil
+}
+
+// Unmanaged is a file in a scene directory which matches SCENE_NAME_QUERY,
+// but the app cannot manage as a version of an element.
+type Unmanaged struct {
+	// Name is a slash separated path relative to the scene directory.
+	Name string
+	Path string
+	// Reason is why the file is not managed by the app.
+	Reason string
+}
+
+// sceneMatch is a scene file that matches the scene name query.
+type sceneMatch struct {
+	Name    string
+	Elem    string
+	Ver     string
+	Program *Program
+}
+
+// matchSceneFiles finds scene files in the scene directory of environs, those match the scene name query.
+// It also returns files those match the query but cannot be elements.
+// It only reads the directory, and doesn't look into the files.
+func (a *App) matchSceneFiles(env []string) (string, []sceneMatch, []*Unmanaged, error) {
 	sceneDir := getEnv("SCENE_DIR", env)
 	if sceneDir == "" {
-		return nil, fmt.Errorf("no scene directory information: check SCENE_DIR environ")
+		return "", nil, nil, fmt.Errorf("no scene directory information: check SCENE_DIR environ")
 	}
 	sceneDir = evalEnvString(sceneDir, env)
 	sceneName := getEnv("SCENE_NAME_QUERY", env)
 	sceneName = evalEnvString(sceneName, env)
 	reName, err := regexp.Compile("^" + sceneName + "$") // match as a whole
 	if err != nil {
-		return nil, err
+		return "", nil, nil, err
 	}
-	files, err := os.ReadDir(sceneDir)
+	matches := make([]sceneMatch, 0)
+	unmanaged := make([]*Unmanaged, 0)
+	files, err := listSceneFiles(sceneDir, sceneDepth(env))
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
-			return nil, err
+			return "", nil, nil, err
 		}
-		return []*Elem{}, nil
+		return sceneDir, matches, unmanaged, nil
 	}
 	programOf := make(map[string]*Program)
 	for _, p := range a.config.Programs {
 		programOf[p.Ext] = p
 	}
-	elem := make(map[string]*Elem, 0)
+	skip := func(name, reason string) {
+		unmanaged = append(unmanaged, &Unmanaged{
+			Name:   name,
+			Path:   sceneDir + "/" + name,
+			Reason: reason,
+		})
+	}
 	for _, f := range files {
-		if f.IsDir() {
+		name := f.Name
+		idxs := reName.FindStringSubmatchIndex(name)
+		if idxs == nil {
 			continue
 		}
-		name := f.Name()
-		idxs := reName.FindStringSubmatchIndex(name)
 		el := string(reName.ExpandString([]byte{}, "$ELEM", name, idxs))
 		ver := string(reName.ExpandString([]byte{}, "$VER", name, idxs))
 		ext := string(reName.ExpandString([]byte{}, "$EXT", name, idxs))
 		extra := string(reName.ExpandString([]byte{}, "$EXTRA", name, idxs))
 		if extra != "" {
+			skip(name, "has extra string: "+extra)
 			continue
 		}
 		p := programOf[ext]
 		if p == nil {
+			if f.IsDir {
+				// probably a sub directory that holds scenes.
+				continue
+			}
+			skip(name, "no program for extension: "+ext)
+			continue
+		}
+		if f.IsDir != p.DirScene {
+			if f.IsDir {
+				skip(name, p.Name+" scene should be a file")
+			} else {
+				skip(name, p.Name+" scene should be a directory")
+			}
 			continue
 		}
-		e := elem[el+"/"+p.Name]
+		matches = append(matches, sceneMatch{Name: name, Elem: el, Ver: ver, Program: p})
+	}
+	return sceneDir, matches, unmanaged, nil
+}
+
+// listElements returns elements of a part entry, and files those match the scene name
+// but cannot be elements.
+func (a *App) listElements(path string) ([]*Elem, []*Unmanaged, error) {
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	sceneDir, matches, unmanaged, err := a.matchSceneFiles(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	scheme, err := verSchemeOf(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	elem := make(map[string]*Elem, 0)
+	for _, m := range matches {
+		e := elem[m.Elem+"/"+m.Program.Name]
 		if e == nil {
 			e = &Elem{
-				Name:    el,
-				Program: p.Name,
+				Name:    m.Elem,
+				Program: m.Program.Name,
 			}
 		}
-		v := Version{Name: ver, Scene: sceneDir + "/" + name}
-		if strings.HasPrefix(ver, "v") {
-			ver = ver[1:]
-		}
-		num, err := strconv.Atoi(ver)
+		v := Version{Name: m.Ver, Scene: sceneDir + "/" + m.Name}
+		v.Num, _ = scheme.Parse(m.Ver)
+		err = statVersion(&v)
 		if err != nil {
-			num = -1
+			if errors.Is(err, os.ErrNotExist) {
+				// removed while listing
+				continue
+			}
+			return nil, nil, err
 		}
-		v.Num = num
 		e.Versions = append(e.Versions, v)
-		elem[el+"/"+p.Name] = e
+		elem[m.Elem+"/"+m.Program.Name] = e
 	}
+	// SCENE_CHECKSUM environ enables to compute checksums of all versions.
+	checksum := getEnv("SCENE_CHECKSUM", env) != ""
 	elems := make([]*Elem, 0, len(elem))
+	needSums := make([]Version, 0)
 	for _, el := range elem {
-		sort.Slice(el.Versions, func(i, j int) bool {
-			cmp := el.Versions[i].Num - el.Versions[j].Num
-			if cmp != 0 {
-				return cmp > 0
-			}
-			// prefer version having more digits
-			return len(el.Versions[i].Name) > len(el.Versions[j].Name)
-		})
+		sortVersions(el.Versions, false)
+		need := checkVersions(el.Versions, a.program[el.Program], checksum, &a.checksums)
+		needSums = append(needSums, need...)
+		if a.state.Options.SortVersionsByTime {
+			sortVersions(el.Versions, true)
+		}
 		elems = append(elems, el)
 	}
 	sort.Slice(elems, func(i, j int) bool {
@@ -1209,7 +1675,13 @@ func (a *App) ListElements(path string) ([]*Elem, error) {
 		cmp = strings.Compare(elems[i].Program, elems[j].Program)
 		return cmp <= 0
 	})
-	return elems, nil
+	sort.Slice(unmanaged, func(i, j int) bool {
+		return unmanaged[i].Name < unmanaged[j].Name
+	})
+	if len(needSums) != 0 {
+		go a.computeChecksums(path, needSums)
+	}
+	return elems, unmanaged, nil
 }
 
 func (a *App) LastVersionOfElement(path, elem, prog string) (string, error) {
@@ -1234,8 +1706,12 @@ func (a *App) LastVersionOfElement(path, elem, prog string) (string, error) {
 	if sceneName == "" {
 		return "", fmt.Errorf("no scene name information: check " + sceneNameEnv + " environ")
 	}
+	scheme, err := verSchemeOf(env)
+	if err != nil {
+		return "", err
+	}
 	env = append(env, "ELEM="+elem)
-	env = append(env, `VER=(?P<VER>[vV]\d+)`)
+	env = append(env, "VER="+scheme.Query())
 	env = append(env, "EXT="+pg.Ext)
 	sceneName = evalEnvString(sceneName, env)
 	scene := sceneDir + "/" + sceneName
@@ -1244,7 +1720,7 @@ func (a *App) LastVersionOfElement(path, elem, prog string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	files, err := os.ReadDir(sceneDir)
+	files, err := listSceneFiles(sceneDir, sceneDepth(env))
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			return "", err
@@ -1253,37 +1729,23 @@ func (a *App) LastVersionOfElement(path, elem, prog string) (string, error) {
 	}
 	vers := make([]Version, 0)
 	for _, f := range files {
-		if f.IsDir() {
+		if f.IsDir != pg.DirScene {
 			continue
 		}
-		name := f.Name()
+		name := f.Name
 		idxs := reName.FindStringSubmatchIndex(name)
 		if idxs == nil {
 			continue
 		}
 		ver := string(reName.ExpandString([]byte{}, "$VER", name, idxs))
 		v := Version{Name: ver}
-		if strings.HasPrefix(ver, "v") {
-			ver = ver[1:]
-		}
-		num, err := strconv.Atoi(ver)
-		if err != nil {
-			num = -1
-		}
-		v.Num = num
+		v.Num, _ = scheme.Parse(ver)
 		vers = append(vers, v)
 	}
 	if len(vers) == 0 {
 		return "", &ElemNotExistError{elem: elem}
 	}
-	sort.Slice(vers, func(i, j int) bool {
-		cmp := vers[i].Num - vers[j].Num
-		if cmp != 0 {
-			return cmp > 0
-		}
-		// prefer version having more digits
-		return len(vers[i].Name) > len(vers[j].Name)
-	})
+	sortVersions(vers, false)
 	return vers[0].Name, nil
 }
 
@@ -1317,8 +1779,12 @@ func (a *App) SceneFile(path, elem, ver, prog string) (string, error) {
 	if sceneName == "" {
 		return "", fmt.Errorf("no scene name information: check " + sceneNameEnv + " environ")
 	}
+	scheme, err := verSchemeOf(env)
+	if err != nil {
+		return "", err
+	}
 	env = append(env, "ELEM="+elem)
-	env = append(env, "VER="+ver)
+	env = append(env, "VER="+scheme.Normalize(ver))
 	env = append(env, "EXT="+pg.Ext)
 	sceneName = evalEnvString(sceneName, env)
 	scene := sceneDir + "/" + sceneName
@@ -1326,6 +1792,37 @@ func (a *App) SceneFile(path, elem, ver, prog string) (string, error) {
 	return scene, nil
 }
 
+// VersionNote returns the note of a version.
+func (a *App) VersionNote(path, elem, ver, prog string) (string, error) {
+	scene, err := a.SceneFile(path, elem, ver, prog)
+	if err != nil {
+		return "", err
+	}
+	meta, err := readSceneMeta(scene)
+	if err != nil {
+		return "", err
+	}
+	if meta == nil {
+		return "", nil
+	}
+	return meta.Note, nil
+}
+
+// SetVersionNote sets the note of a version.
+func (a *App) SetVersionNote(path, elem, ver, prog, note string) error {
+	scene, err := a.SceneFile(path, elem, ver, prog)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(scene)
+	if err != nil {
+		return err
+	}
+	return updateSceneMeta(scene, func(meta *SceneMeta) {
+		meta.Note = note
+	})
+}
+
 // OpenScene opens a scene that corresponds to the args (path, elem, ver, prog).
 func (a *App) OpenScene(path, elem, ver, prog string) error {
 	if ver == "" {
@@ -1356,21 +1853,21 @@ func (a *App) OpenScene(path, elem, ver, prog string) error {
 	if sceneName == "" {
 		return fmt.Errorf("no scene name information: check " + sceneNameEnv + " environ")
 	}
+	scheme, err := verSchemeOf(env)
+	if err != nil {
+		return err
+	}
 	env = append(env, "ELEM="+elem)
-	env = append(env, "VER="+ver)
+	env = append(env, "VER="+scheme.Normalize(ver))
 	env = append(env, "EXT="+pg.Ext)
 	env = append(env, "FORGE_SESSION="+a.session)
 	sceneName = evalEnvString(sceneName, env)
 	scene := sceneDir + "/" + sceneName
 	scene = evalEnvString(scene, env)
 	env = append(env, "SCENE="+scene)
-	openCmd := make([]string, 0, len(pg.OpenCmd))
-	for _, c := range pg.OpenCmd {
-		c = evalEnvString(c, env)
-		c = strings.TrimSpace(c)
-		if c != "" {
-			openCmd = append(openCmd, c)
-		}
+	openCmd, err := a.programCmd(pg, pg.OpenCmd, env)
+	if err != nil {
+		return err
 	}
 	cmd := exec.Command(openCmd[0], openCmd[1:]...)
 	cmd.Dir = filepath.Dir(scene)
@@ -1378,9 +1875,43 @@ func (a *App) OpenScene(path, elem, ver, prog string) error {
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	err = removeStaleSceneLock(scene)
+	if err != nil {
+		log.Printf("remove stale lock: %v", err)
+	}
+	lock, err := activeSceneLock(scene)
+	if err != nil {
+		log.Printf("read scene lock: %v", err)
+		lock = nil
+	}
+	if lock != nil {
+		msg := fmt.Sprintf("%s has opened the scene on %s since %s.\nOpen anyway?", lock.User, lock.Host, lock.At.Format("2006-01-02 15:04"))
+		ok, err := a.confirm("Scene Locked", msg)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
 	err = cmd.Start()
 	if err != nil {
 		fmt.Println(err)
+	} else if pg.NoLock {
+		go cmd.Wait()
+	} else {
+		pid := cmd.Process.Pid
+		err = lockScene(scene, a.user, pid)
+		if err != nil {
+			log.Printf("lock scene: %v", err)
+		}
+		go func() {
+			cmd.Wait()
+			err := unlockScene(scene, pid)
+			if err != nil {
+				log.Printf("unlock scene: %v", err)
+			}
+		}()
 	}
 	err = a.addRecentPath(path)
 	if err != nil {
@@ -1395,16 +1926,23 @@ func (a *App) Dir(path string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	dirTmpl, ok := a.config.Dir[ent.Type]
-	if !ok {
+	if _, ok := a.config.Dir[ent.Type]; !ok {
 		return "", fmt.Errorf("directory not specified")
 	}
 	env, err := a.EntryEnvirons(path)
 	if err != nil {
 		return "", err
 	}
-	dir := evalEnvString(dirTmpl, env)
-	return dir, nil
+	return a.entryDir(ent.Type, env)
+}
+
+// entryDir returns directory of an entry type with environs of the entry.
+func (a *App) entryDir(typ string, env []string) (string, error) {
+	dirTmpl, ok := a.config.Dir[typ]
+	if !ok {
+		return "", fmt.Errorf("directory not specified")
+	}
+	return a.ResolvePath(evalEnvString(dirTmpl, env)), nil
 }
 
 // DirExists returns whether the directory path exists in filesystem.
@@ -1438,8 +1976,30 @@ func openPath(path string) error {
 	return err
 }
 
+// copyFile copies src file to dst.
+// It fails if dst already exists, to not overwrite others' work.
+func copyFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if err != nil {
+		w.Close()
+		os.Remove(dst)
+		return err
+	}
+	return w.Close()
+}
+
 // Open opens a directory or run a file.
 func (a *App) Open(path string) error {
+	path = a.ResolvePath(path)
 	_, err := os.Stat(path)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
@@ -1451,6 +2011,7 @@ func (a *App) Open(path string) error {
 
 // OpenDir opens a directory using native file browser of current OS.
 func (a *App) OpenDir(dir string) error {
+	dir = a.ResolvePath(dir)
 	_, err := os.Stat(dir)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvRefs(t *testing.T) {
+	cases := []struct {
+		v    string
+		want []string
+	}{
+		{"rez", []string{}},
+		{"$REZ_PACKAGES", []string{"REZ_PACKAGES"}},
+		{"${SHOW}_tools", []string{"SHOW"}},
+		{"$SHOW/$SHOT-${TASK}", []string{"SHOW", "SHOT", "TASK"}},
+	}
+	for _, c := range cases {
+		got := envRefs(c.v)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s: got %v, want %v", c.v, got, c.want)
+		}
+	}
+}
+
+func TestWrapCmd(t *testing.T) {
+	cmd := []string{"houdini", "/show/shot 010/a.v001.hip"}
+	wrapper := []string{"rez", "env", "$REZ_PACKAGES", "--"}
+	cases := []struct {
+		label   string
+		wrapper []string
+		env     []string
+		want    []string
+	}{
+		{
+			label:   "no wrapper",
+			wrapper: nil,
+			env:     []string{"REZ_PACKAGES=houdini"},
+			want:    []string{"houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			label:   "wrapped",
+			wrapper: wrapper,
+			env:     []string{"REZ_PACKAGES=houdini-19.5"},
+			want:    []string{"rez", "env", "houdini-19.5", "--", "houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			// an environ holds multiple arguments, but arguments of cmd are kept as is.
+			label:   "split environ",
+			wrapper: wrapper,
+			env:     []string{"REZ_PACKAGES=houdini-19.5  show_tools"},
+			want:    []string{"rez", "env", "houdini-19.5", "show_tools", "--", "houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			label:   "braced environ",
+			wrapper: []string{"rez", "env", "${SHOW}_tools", "--"},
+			env:     []string{"SHOW=bird"},
+			want:    []string{"rez", "env", "bird_tools", "--", "houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			label:   "undefined environ",
+			wrapper: wrapper,
+			env:     []string{"SHOW=bird"},
+			want:    []string{"houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			label:   "empty environ",
+			wrapper: wrapper,
+			env:     []string{"REZ_PACKAGES="},
+			want:    []string{"houdini", "/show/shot 010/a.v001.hip"},
+		},
+	}
+	for _, c := range cases {
+		got := wrapCmd(cmd, c.wrapper, c.env)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s: got %q, want %q", c.label, got, c.want)
+		}
+	}
+}
+
+func TestProgramCmd(t *testing.T) {
+	a := &App{
+		config: &Config{
+			Wrapper: []string{"rez", "env", "$REZ_PACKAGES", "--"},
+		},
+	}
+	env := []string{
+		"REZ_PACKAGES=houdini-19.5",
+		"SCENE=/show/shot 010/a.v001.hip",
+	}
+	cases := []struct {
+		label   string
+		prog    *Program
+		tmpl    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			label: "config wrapper",
+			prog:  &Program{Name: "houdini"},
+			tmpl:  []string{"houdini", "$SCENE"},
+			want:  []string{"rez", "env", "houdini-19.5", "--", "houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			label: "program wrapper",
+			prog:  &Program{Name: "houdini", Wrapper: []string{"launch", "--"}},
+			tmpl:  []string{"houdini", "${SCENE}"},
+			want:  []string{"launch", "--", "houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			// an empty wrapper of a program disables the config wrapper.
+			label: "no wrapper",
+			prog:  &Program{Name: "houdini", Wrapper: []string{}},
+			tmpl:  []string{"houdini", "$SCENE"},
+			want:  []string{"houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			label: "empty arguments",
+			prog:  &Program{Name: "houdini", Wrapper: []string{}},
+			tmpl:  []string{"houdini", "$UNDEFINED", " ", "$SCENE"},
+			want:  []string{"houdini", "/show/shot 010/a.v001.hip"},
+		},
+		{
+			label:   "no command",
+			prog:    &Program{Name: "houdini"},
+			tmpl:    []string{"$UNDEFINED"},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		got, err := a.programCmd(c.prog, c.tmpl, env)
+		if c.wantErr {
+			if err == nil {
+				t.Fatalf("%s: want error, got %q", c.label, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", c.label, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s: got %q, want %q", c.label, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// defaultDirMode is permission of a directory when the template doesn't specify it.
+const defaultDirMode = 0755
+
+// DirTemplate is a directory that will be created in the directory of an entry.
+type DirTemplate struct {
+	// Path is a path relative to the entry directory, which can have environs. (ex: render/${PART})
+	Path string
+	// Mode is permission of the directory in octal. (ex: 0775)
+	Mode string
+}
+
+// PlannedDir is a directory that doesn't exist yet, and will be created by the template.
+type PlannedDir struct {
+	Path string
+	Mode string
+}
+
+// DirTemplatePlan returns directories those will be created by applying the directory template to the entry,
+// without creating them. It includes the entry directory itself when it doesn't exist.
+func (a *App) DirTemplatePlan(path string) ([]*PlannedDir, error) {
+	return a.applyDirTemplate(path, true)
+}
+
+// ApplyDirTemplate creates the entry directory and it's sub directories defined in the directory template.
+// It returns directories those are newly created.
+func (a *App) ApplyDirTemplate(path string) ([]*PlannedDir, error) {
+	return a.applyDirTemplate(path, false)
+}
+
+func (a *App) applyDirTemplate(path string, dryRun bool) ([]*PlannedDir, error) {
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := a.config.Dir[ent.Type]; !ok {
+		return nil, fmt.Errorf("directory not specified for %s entry", ent.Type)
+	}
+	dir, err := a.Dir(path)
+	if err != nil {
+		return nil, err
+	}
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return nil, err
+	}
+	return makeTemplateDirs(dir, a.config.DirTemplate[ent.Type], env, dryRun)
+}
+
+// makeTemplateDirs creates dir and the template directories in it, with environs evaluated by env.
+// Parents of a template directory those aren't in the template are created with the default mode.
+// It returns directories those are created, or those would be created without creating them when dryRun is true.
+func makeTemplateDirs(dir string, tmpls []*DirTemplate, env []string, dryRun bool) ([]*PlannedDir, error) {
+	dir = filepath.Clean(dir)
+	modes := map[string]fs.FileMode{
+		dir: defaultDirMode,
+	}
+	for _, t := range tmpls {
+		rel := evalEnvString(t.Path, env)
+		if filepath.IsAbs(rel) {
+			return nil, fmt.Errorf("directory template should be a relative path: %s", t.Path)
+		}
+		d := filepath.Join(dir, rel)
+		err := checkInDir(d, dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid directory template: %v", err)
+		}
+		mode := fs.FileMode(defaultDirMode)
+		if t.Mode != "" {
+			m, err := strconv.ParseUint(t.Mode, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid directory template mode for %s: %s", t.Path, t.Mode)
+			}
+			mode = fs.FileMode(m) & fs.ModePerm
+		}
+		modes[d] = mode
+		for p := filepath.Dir(d); p != dir; p = filepath.Dir(p) {
+			if _, ok := modes[p]; !ok {
+				modes[p] = defaultDirMode
+			}
+		}
+	}
+	dirs := make([]string, 0, len(modes))
+	for d := range modes {
+		dirs = append(dirs, d)
+	}
+	// parents should be created before their children.
+	sort.Strings(dirs)
+	planned := make([]*PlannedDir, 0)
+	for _, d := range dirs {
+		_, err := os.Stat(d)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		mode := modes[d]
+		if !dryRun {
+			err = os.MkdirAll(d, mode)
+			if err != nil {
+				return nil, err
+			}
+			// MkdirAll is affected by umask.
+			err = os.Chmod(d, mode)
+			if err != nil {
+				return nil, err
+			}
+		}
+		planned = append(planned, &PlannedDir{Path: d, Mode: fmt.Sprintf("%#o", mode)})
+	}
+	return planned, nil
+}
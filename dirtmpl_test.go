@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMakeTemplateDirs(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "show", "shot")
+	err := os.MkdirAll(filepath.Join(dir, "scene"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := []string{"PART=fx"}
+	tmpls := []*DirTemplate{
+		{Path: "scene"},
+		{Path: "render/${PART}", Mode: "0775"},
+		{Path: "private", Mode: "700"},
+		{Path: "cache/$PART/sim"},
+	}
+	want := []*PlannedDir{
+		{Path: filepath.Join(dir, "cache"), Mode: "0755"},
+		{Path: filepath.Join(dir, "cache", "fx"), Mode: "0755"},
+		{Path: filepath.Join(dir, "cache", "fx", "sim"), Mode: "0755"},
+		{Path: filepath.Join(dir, "private"), Mode: "0700"},
+		{Path: filepath.Join(dir, "render"), Mode: "0755"},
+		{Path: filepath.Join(dir, "render", "fx"), Mode: "0775"},
+	}
+	plan, err := makeTemplateDirs(dir, tmpls, env, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Fatalf("plan: got %v, want %v", plannedPaths(plan), plannedPaths(want))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "render")); !os.IsNotExist(err) {
+		t.Fatalf("dry run created a directory: %v", err)
+	}
+	made, err := makeTemplateDirs(dir, tmpls, env, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(made, plan) {
+		t.Fatalf("applied: got %v, planned %v", plannedPaths(made), plannedPaths(plan))
+	}
+	// every directory made is in the plan, with the planned mode.
+	modes := make(map[string]string)
+	for _, p := range plan {
+		modes[p.Path] = p.Mode
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir || path == filepath.Join(dir, "scene") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode, ok := modes[path]
+		if !ok {
+			t.Fatalf("directory not planned: %s", path)
+		}
+		if got := fmt.Sprintf("%#o", fi.Mode().Perm()); got != mode {
+			t.Fatalf("%s: got mode %s, want %s", path, got, mode)
+		}
+		delete(modes, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modes) != 0 {
+		t.Fatalf("planned directories not made: %v", modes)
+	}
+	// nothing is left to do.
+	plan, err = makeTemplateDirs(dir, tmpls, env, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 0 {
+		t.Fatalf("got plan %v after applied", plannedPaths(plan))
+	}
+}
+
+func TestMakeTemplateDirsEntryDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "show", "shot")
+	plan, err := makeTemplateDirs(dir, nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*PlannedDir{{Path: dir, Mode: "0755"}}
+	if !reflect.DeepEqual(plan, want) {
+		t.Fatalf("got %v, want %v", plannedPaths(plan), plannedPaths(want))
+	}
+}
+
+func TestMakeTemplateDirsInvalid(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shot")
+	cases := []struct {
+		label string
+		tmpl  *DirTemplate
+		env   []string
+	}{
+		{label: "absolute", tmpl: &DirTemplate{Path: "/tmp/render"}},
+		{label: "parent", tmpl: &DirTemplate{Path: "../other"}},
+		{label: "parent in the middle", tmpl: &DirTemplate{Path: "render/../../other"}},
+		{label: "entry dir", tmpl: &DirTemplate{Path: "."}},
+		{label: "parent from environ", tmpl: &DirTemplate{Path: "$PART"}, env: []string{"PART=../other"}},
+		{label: "absolute from environ", tmpl: &DirTemplate{Path: "${ROOT}/render"}, env: []string{"ROOT=/tmp"}},
+		{label: "invalid mode", tmpl: &DirTemplate{Path: "render", Mode: "0789"}},
+		{label: "symbolic mode", tmpl: &DirTemplate{Path: "render", Mode: "rwxr-xr-x"}},
+	}
+	for _, c := range cases {
+		for _, dryRun := range []bool{true, false} {
+			plan, err := makeTemplateDirs(dir, []*DirTemplate{c.tmpl}, c.env, dryRun)
+			if err == nil {
+				t.Fatalf("%s: want error, got %v", c.label, plannedPaths(plan))
+			}
+		}
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("invalid template created a directory: %v", err)
+	}
+}
+
+// plannedPaths returns paths and modes of planned directories, to be printed.
+func plannedPaths(plan []*PlannedDir) []string {
+	paths := make([]string, 0, len(plan))
+	for _, p := range plan {
+		paths = append(paths, p.Path+" "+p.Mode)
+	}
+	return paths
+}
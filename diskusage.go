@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imagvfx/forge"
+	wails "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// diskUsageWorkers is the number of directories read at the same time.
+// Reading directories on network filesystems is bound by latency, not by disk.
+const diskUsageWorkers = 16
+
+// diskUsageProgressInterval is the interval of "diskUsageProgress" events.
+const diskUsageProgressInterval = 500 * time.Millisecond
+
+// DiskUsage is disk usage of an entry's directory.
+// Sizes are in bytes.
+type DiskUsage struct {
+	Path  string
+	Size  int64
+	Files int
+	// ByEntry is sizes of the entry and it's sub entries, when sub entries are included.
+	ByEntry map[string]int64
+	// ByElement is sizes of elements, keyed by "entry: elem (program)".
+	ByElement map[string]int64
+	// ByVersion is sizes of versions, keyed by "entry: elem / ver (program)".
+	ByVersion map[string]int64
+	// ByType is sizes of files by their lower cased extensions.
+	// Files without an extension are counted as "(none)".
+	ByType map[string]int64
+	// Errors are errors those are skipped while walking. (ex: permission denied)
+	Errors []string
+	At     time.Time
+}
+
+// diskUsageVersion is a version that a file belongs to.
+type diskUsageVersion struct {
+	elem string
+	ver  string
+}
+
+// DiskUsage reports disk usage of the entry's directory.
+// When withSubs is true, directories of the sub entries at every depth are included as well.
+// Results are cached until refresh is true, as walking a large tree takes long.
+// Progress is emitted as "diskUsageProgress" event with the path, number of files and size so far.
+func (a *App) DiskUsage(path string, withSubs, refresh bool) (*DiskUsage, error) {
+	key := fmt.Sprintf("%s:%v", path, withSubs)
+	if !refresh {
+		a.diskUsageLock.Lock()
+		du := a.diskUsage[key]
+		a.diskUsageLock.Unlock()
+		if du != nil {
+			return du, nil
+		}
+	}
+	v, err := a.diskUsageFlight.Do(key, func() (interface{}, error) {
+		return a.walkDiskUsage(path, withSubs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	du := v.(*DiskUsage)
+	a.diskUsageLock.Lock()
+	a.diskUsage[key] = du
+	a.diskUsageLock.Unlock()
+	return du, nil
+}
+
+func (a *App) walkDiskUsage(path string, withSubs bool) (*DiskUsage, error) {
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	ents := []*forge.Entry{ent}
+	if withSubs {
+		subs, err := a.allSubEntries(path)
+		if err != nil {
+			return nil, err
+		}
+		ents = append(ents, subs...)
+	}
+	// user environs are the same for all entries.
+	userEnv, err := a.userEnvirons()
+	if err != nil {
+		return nil, err
+	}
+	// entDir maps a directory to the entry.
+	entDir := make(map[string]string)
+	// versionOf maps a scene to the version.
+	versionOf := make(map[string]diskUsageVersion)
+	for _, e := range ents {
+		if _, ok := a.config.Dir[e.Type]; !ok {
+			continue
+		}
+		env, err := a.entryEnvironsWith(e.Path, userEnv)
+		if err != nil {
+			return nil, err
+		}
+		dir, err := a.entryDir(e.Type, env)
+		if err != nil {
+			return nil, err
+		}
+		entDir[filepath.Clean(dir)] = e.Path
+		if e.Type != a.config.LeafEntryType {
+			continue
+		}
+		// only names of scenes are needed, listing elements reads the files too much.
+		sceneDir, matches, _, err := a.matchSceneFiles(env)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			elem := m.Elem
+			if elem == "" {
+				elem = "[main]"
+			}
+			elem = e.Path + ": " + elem
+			versionOf[filepath.Clean(sceneDir+"/"+m.Name)] = diskUsageVersion{
+				elem: elem + " (" + m.Program.Name + ")",
+				ver:  elem + " / " + m.Ver + " (" + m.Program.Name + ")",
+			}
+		}
+	}
+	if len(entDir) == 0 {
+		return nil, fmt.Errorf("directory not specified for %s", path)
+	}
+	// walk only top directories, as sub entry directories are usually inside of the parent's.
+	roots := make([]string, 0)
+	for dir := range entDir {
+		nested := false
+		for other := range entDir {
+			if other != dir && checkInDir(dir, other) == nil {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			roots = append(roots, dir)
+		}
+	}
+	du := &DiskUsage{
+		Path:      path,
+		ByEntry:   make(map[string]int64),
+		ByElement: make(map[string]int64),
+		ByVersion: make(map[string]int64),
+		ByType:    make(map[string]int64),
+		Errors:    make([]string, 0),
+	}
+	var lock sync.Mutex
+	lastProgress := time.Now()
+	// lookup finds the file or it's nearest parent directory, for which has returns true.
+	lookup := func(file, root string, has func(string) bool) (string, bool) {
+		for d := file; ; d = filepath.Dir(d) {
+			if has(d) {
+				return d, true
+			}
+			if d == root || d == filepath.Dir(d) {
+				return "", false
+			}
+		}
+	}
+	isEntDir := func(d string) bool {
+		_, ok := entDir[d]
+		return ok
+	}
+	isScene := func(d string) bool {
+		_, ok := versionOf[d]
+		return ok
+	}
+	add := func(file, root string, size int64) {
+		lock.Lock()
+		defer lock.Unlock()
+		du.Size += size
+		du.Files++
+		if withSubs {
+			if d, ok := lookup(file, root, isEntDir); ok {
+				du.ByEntry[entDir[d]] += size
+			}
+		}
+		if scene, ok := lookup(file, root, isScene); ok {
+			v := versionOf[scene]
+			du.ByElement[v.elem] += size
+			du.ByVersion[v.ver] += size
+		}
+		ext := strings.ToLower(filepath.Ext(file))
+		if ext == "" {
+			ext = "(none)"
+		}
+		du.ByType[ext] += size
+		if time.Since(lastProgress) > diskUsageProgressInterval {
+			lastProgress = time.Now()
+			wails.EventsEmit(a.ctx, "diskUsageProgress", path, du.Files, du.Size)
+		}
+	}
+	addErr := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		du.Errors = append(du.Errors, err.Error())
+	}
+	for _, root := range roots {
+		_, err := os.Stat(root)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		walkDirConcurrent(root, diskUsageWorkers, func(file string, size int64) {
+			add(file, root, size)
+		}, addErr)
+	}
+	du.At = time.Now()
+	wails.EventsEmit(a.ctx, "diskUsageProgress", path, du.Files, du.Size)
+	return du, nil
+}
+
+// allSubEntries returns sub entries of an entry at every depth.
+// Entries of a depth are listed concurrently, as it sends a request for each entry.
+func (a *App) allSubEntries(path string) ([]*forge.Entry, error) {
+	all := make([]*forge.Entry, 0)
+	parents := []string{path}
+	for len(parents) != 0 {
+		subs := make([][]*forge.Entry, len(parents))
+		errs := make([]error, len(parents))
+		sem := make(chan struct{}, diskUsageWorkers)
+		var wg sync.WaitGroup
+		for i, p := range parents {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, p string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				subs[i], errs[i] = subEntries(a.host, a.session, p)
+			}(i, p)
+		}
+		wg.Wait()
+		parents = parents[:0]
+		for i := range subs {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			for _, e := range subs[i] {
+				all = append(all, e)
+				parents = append(parents, e.Path)
+			}
+		}
+	}
+	return all, nil
+}
+
+// walkDirConcurrent walks the directory tree with the number of workers,
+// and calls onFile for each file with it's size.
+// Symbolic links are counted as files, and are not followed.
+// Errors while walking are passed to onErr, and the walk continues.
+func walkDirConcurrent(root string, workers int, onFile func(string, int64), onErr func(error)) {
+	var (
+		lock    sync.Mutex
+		cond    = sync.NewCond(&lock)
+		queue   = []string{root}
+		pending = 1
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				lock.Lock()
+				for len(queue) == 0 && pending != 0 {
+					cond.Wait()
+				}
+				if pending == 0 {
+					lock.Unlock()
+					return
+				}
+				dir := queue[len(queue)-1]
+				queue = queue[:len(queue)-1]
+				lock.Unlock()
+
+				ents, err := os.ReadDir(dir)
+				if err != nil {
+					onErr(err)
+				}
+				subs := make([]string, 0)
+				for _, e := range ents {
+					p := filepath.Join(dir, e.Name())
+					if e.IsDir() {
+						subs = append(subs, p)
+						continue
+					}
+					fi, err := e.Info()
+					if err != nil {
+						onErr(err)
+						continue
+					}
+					onFile(p, fi.Size())
+				}
+
+				lock.Lock()
+				queue = append(queue, subs...)
+				pending += len(subs) - 1
+				cond.Broadcast()
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
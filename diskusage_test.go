@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWalkDirConcurrent(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]int{
+		"a":         1,
+		"b/c":       2,
+		"b/d/e":     3,
+		"b/d/f/g/h": 4,
+		"i/j":       5,
+	}
+	for f, size := range files {
+		p := filepath.Join(root, f)
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(p, make([]byte, size), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.MkdirAll(filepath.Join(root, "empty/empty"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// files in an unreadable directory are not counted, and the walk goes on.
+	unreadable := filepath.Join(root, "unreadable")
+	err = os.MkdirAll(unreadable, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(unreadable, "k"), make([]byte, 100), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chmod(unreadable, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(unreadable, 0755)
+	_, rerr := os.ReadDir(unreadable)
+	canRead := rerr == nil
+
+	var (
+		lock  sync.Mutex
+		size  int64
+		count int
+		errs  []error
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		walkDirConcurrent(root, 4, func(file string, n int64) {
+			lock.Lock()
+			defer lock.Unlock()
+			size += n
+			count++
+		}, func(err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			errs = append(errs, err)
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("walk doesn't return")
+	}
+	wantSize, wantCount := int64(15), 5
+	if canRead {
+		// root can read the directory anyway.
+		wantSize, wantCount = 115, 6
+	} else if len(errs) != 1 {
+		t.Errorf("got errors %v, want an error of the unreadable directory", errs)
+	}
+	if size != wantSize || count != wantCount {
+		t.Fatalf("got %d files of %d bytes, want %d files of %d bytes", count, size, wantCount, wantSize)
+	}
+}
+
+func TestWalkDirConcurrentMissingRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "missing")
+	errs := 0
+	walkDirConcurrent(root, 4, func(string, int64) {
+		t.Error("no files should be found")
+	}, func(error) {
+		errs++
+	})
+	if errs != 1 {
+		t.Fatalf("got %d errors, want 1", errs)
+	}
+}
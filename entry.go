@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/imagvfx/forge"
+)
+
+// SubEntryTypes returns entry types those can be created under the entry.
+// They are defined in .sub_entry_types property of the entry.
+func (a *App) SubEntryTypes(path string) ([]string, error) {
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	types := make([]string, 0)
+	p := ent.Property[".sub_entry_types"]
+	if p == nil {
+		return types, nil
+	}
+	for _, t := range strings.Split(p.Value, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// EntryTemplates returns names of template entries for a new sub entry of the entry.
+// Templates are entries of the type under the forge path defined by ENTRY_TEMPLATE_ROOT environ.
+// It returns an empty list when the environ is not defined.
+func (a *App) EntryTemplates(path, typ string) ([]string, error) {
+	root, err := a.entryTemplateRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	if root == "" {
+		return names, nil
+	}
+	tmpls, err := a.ListAllEntries(root)
+	if err != nil {
+		return nil, fmt.Errorf("list entry templates: %v", err)
+	}
+	for _, t := range tmpls {
+		if t.Type != typ {
+			continue
+		}
+		names = append(names, t.Name())
+	}
+	return names, nil
+}
+
+func (a *App) entryTemplateRoot(path string) (string, error) {
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return "", err
+	}
+	root := getEnv("ENTRY_TEMPLATE_ROOT", env)
+	return strings.TrimSuffix(root, "/"), nil
+}
+
+// NewEntry creates a sub entry of the entry with the name and type.
+// When tmpl is not empty, properties and sub entries of the template entry are copied to the new entry.
+// Directory of the new entry will be created, if the config has one for the type.
+// It returns path of the new entry.
+func (a *App) NewEntry(path, name, typ, tmpl string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("entry name not specified")
+	}
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("entry name cannot have '/': %s", name)
+	}
+	types, err := a.SubEntryTypes(path)
+	if err != nil {
+		return "", err
+	}
+	if len(types) != 0 {
+		ok := false
+		for _, t := range types {
+			if t == typ {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("cannot create %s entry under %s: allowed types are %s", typ, path, strings.Join(types, ", "))
+		}
+	}
+	var tmplEnt *forge.Entry
+	if tmpl != "" {
+		root, err := a.entryTemplateRoot(path)
+		if err != nil {
+			return "", err
+		}
+		if root == "" {
+			return "", fmt.Errorf("no entry template information: check ENTRY_TEMPLATE_ROOT environ")
+		}
+		tmplEnt, err = a.GetEntry(root + "/" + tmpl)
+		if err != nil {
+			return "", fmt.Errorf("get entry template: %v", err)
+		}
+		if tmplEnt.Type != typ {
+			return "", fmt.Errorf("entry template %s is a %s, not %s", tmpl, tmplEnt.Type, typ)
+		}
+	}
+	newPath := strings.TrimSuffix(path, "/") + "/" + name
+	err = addEntry(a.host, a.session, newPath, typ)
+	if err != nil {
+		return "", err
+	}
+	if tmplEnt != nil {
+		err = a.copyEntryTemplate(tmplEnt, newPath)
+		if err != nil {
+			return "", fmt.Errorf("entry created, but applying template failed: %v", err)
+		}
+	}
+	err = a.scaffoldDir(newPath)
+	if err != nil {
+		return "", fmt.Errorf("entry created, but creating directory failed: %v", err)
+	}
+	err = a.refreshEntries(newPath)
+	if err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
+
+// copyEntryTemplate copies properties and sub entries of the template entry to the entry recursively.
+// Hidden properties (those start with '.') and empty properties are not copied.
+// Sub entries those already exist are kept, but the template is still applied to them.
+func (a *App) copyEntryTemplate(tmpl *forge.Entry, path string) error {
+	for name, p := range tmpl.Property {
+		if strings.HasPrefix(name, ".") || p.Value == "" {
+			continue
+		}
+		err := updateProperty(a.host, a.session, path, name, p.Value)
+		if err != nil {
+			return fmt.Errorf("copy property %s: %v", name, err)
+		}
+	}
+	subs, err := a.ListAllEntries(tmpl.Path)
+	if err != nil {
+		return err
+	}
+	// forge might already have created default sub entries.
+	exists := make(map[string]bool)
+	existing, err := a.ListAllEntries(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range existing {
+		exists[e.Name()] = true
+	}
+	for _, sub := range subs {
+		subPath := path + "/" + sub.Name()
+		if !exists[sub.Name()] {
+			err := addEntry(a.host, a.session, subPath, sub.Type)
+			if err != nil {
+				return err
+			}
+		}
+		err = a.copyEntryTemplate(sub, subPath)
+		if err != nil {
+			return err
+		}
+		err = a.scaffoldDir(subPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scaffoldDir creates directory of the entry with it's directory template.
+// It does nothing when the config doesn't have a directory for the entry type.
+func (a *App) scaffoldDir(path string) error {
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := a.config.Dir[ent.Type]; !ok {
+		return nil
+	}
+	_, err = a.ApplyDirTemplate(path)
+	if err != nil {
+		return err
+	}
+	return nil
+}
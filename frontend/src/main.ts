@@ -1,6 +1,7 @@
 'use strict';
 
 import * as App from '../wailsjs/go/main/App.js'
+import { EventsOn } from '../wailsjs/runtime/runtime.js'
 
 window.onload = async function() {
 	try {
@@ -15,6 +16,35 @@ window.onload = async function() {
 	entryList.dataset.oldPath = path;
 }
 
+EventsOn("elementsChanged", async function(path: string) {
+	let app = await App.State();
+	if (app.Path != path) {
+		return;
+	}
+	App.ReloadEntry().then(redrawAll).catch(logError);
+});
+
+EventsOn("checksumsChanged", async function(path: string) {
+	let app = await App.State();
+	if (app.Path != path) {
+		return;
+	}
+	App.ReloadEntry().then(redrawAll).catch(logError);
+});
+
+EventsOn("diskUsageProgress", function(path: string, files: number, size: number) {
+	log("scanning " + path + ": " + files + " files, " + formatSize(size));
+});
+
+// thumbnailChanged is emitted when a cached thumbnail turned out to be outdated.
+EventsOn("thumbnailChanged", async function(path: string) {
+	let app = await App.State();
+	if (app.Path != path && !app.Path.startsWith(path + "/") && !app.Entries.some((e: any) => e.Path.startsWith(path))) {
+		return;
+	}
+	redrawAll();
+});
+
 function closest(from: HTMLElement, query: string): HTMLElement {
 	return from.closest(query)!
 }
@@ -38,6 +68,67 @@ window.onclick = async function(ev) {
 		let menu = querySelector("#contextMenu");
 		menu.style.display = "none";
 	}
+	let menuItem = closest(target, ".contextMenuItem");
+	if (menuItem) {
+		let menu = querySelector("#contextMenu");
+		menu.style.display = "none";
+		try {
+			let app = await App.State();
+			let action = menuItem.dataset.action as string;
+			let elem = menuItem.dataset.elem as string;
+			let ver = menuItem.dataset.ver as string;
+			let prog = menuItem.dataset.prog as string;
+			if (action == "versionUp") {
+				// alt+click versions up the scene without launching the program.
+				// the note of the source version is not about the new version, use the version up input for a note.
+				let newVer = await App.VersionUp(app.Path, elem, ver, prog, "", !altLike);
+				log("versioned up: " + elem + " / " + newVer);
+			} else if (action == "archive") {
+				await App.ArchiveVersion(app.Path, elem, ver, prog);
+				log("archived: " + elem + " / " + ver);
+			} else if (action == "archiveOld") {
+				let n = await App.ArchiveOldVersions(app.Path, elem, prog, 1);
+				log("archived " + n + " versions of " + elem);
+			} else if (action == "deleteVersion") {
+				let deleted = await App.DeleteVersion(app.Path, elem, ver, prog);
+				if (deleted) {
+					log("deleted: " + elem + " / " + ver);
+				}
+			} else if (action == "deleteElement") {
+				let deleted = await App.DeleteElement(app.Path, elem, prog);
+				if (deleted) {
+					log("deleted: " + elem);
+				}
+			} else if (action == "thumbnailFromFile") {
+				let uploaded = await App.UploadThumbnailFromFile(app.Path);
+				if (uploaded) {
+					log("thumbnail uploaded: " + app.Path);
+				}
+			} else if (action == "thumbnailFromClipboard") {
+				let data = await clipboardImage();
+				await App.UploadThumbnailData(app.Path, data);
+				log("thumbnail uploaded: " + app.Path);
+			} else if (action == "applyDirTemplate") {
+				let created = await App.ApplyDirTemplate(menuItem.dataset.path as string);
+				log("created " + created.length + " directories");
+			} else if (action == "diskUsage" || action == "diskUsageSubs") {
+				// alt+click walks the directories again, instead of using the cached report.
+				let du = await App.DiskUsage(menuItem.dataset.path as string, action == "diskUsageSubs", altLike);
+				showDiskUsage(du);
+				log("disk usage: " + du.Path + " " + formatSize(du.Size));
+				return;
+			} else if (action == "thumbnailFromSequence") {
+				await App.UploadThumbnailFromSequence(app.Path, menuItem.dataset.path as string);
+				log("thumbnail uploaded: " + app.Path);
+			}
+			if (action) {
+				await App.ReloadEntry();
+				redrawAll();
+			}
+		} catch (err: any) {
+			logError(err);
+		}
+	}
 	let bookmark = closest(target, ".entryBookmark");
 	if (bookmark) {
 		try {
@@ -141,6 +232,21 @@ window.onclick = async function(ev) {
 			}
 		}
 	}
+	let unmanaged = closest(target, "#entryList .unmanaged");
+	if (unmanaged && ev.detail == 2) {
+		// double click
+		App.Open(unmanaged.dataset.path as string).catch(logError);
+	}
+	let sequence = closest(target, "#entryList .sequence");
+	if (sequence && ev.detail == 2) {
+		// double click
+		try {
+			let app = await App.State();
+			await App.OpenSequence(app.Path, sequence.dataset.path as string);
+		} catch (err) {
+			logError(err);
+		}
+	}
 	let recentPath = closest(target, ".recentPath");
 	if (recentPath) {
 		try {
@@ -193,7 +299,8 @@ window.onclick = async function(ev) {
 	}
 	let pathText = closest(target, ".pathText");
 	if (pathText) {
-		let path = pathText.innerText;
+		// the path might be written on another os.
+		let path = await App.ResolvePath(pathText.innerText);
 		if (altLike) {
 			try {
 				await App.Open(path)
@@ -283,6 +390,22 @@ entryList.oncontextmenu = async function(ev) {
 	menu.style.left = ev.pageX + "px";
 	menu.style.top = ev.pageY + "px";
 	let target = ev.target as HTMLElement;
+	let seq = target.closest(".sequence") as HTMLElement;
+	if (seq) {
+		if (seq.dataset.canThumbnail != "true") {
+			// the image format cannot be decoded, and no converter is configured.
+			menu.style.display = "none";
+			return;
+		}
+		menu.style.display = "flex";
+		let label = document.createElement("div");
+		label.classList.add("contextMenuLabel");
+		label.innerText = seq.innerText;
+		let item = newContextMenuItem("thumbnailFromSequence", "use as thumbnail", "", "", "");
+		item.dataset.path = seq.dataset.path;
+		menu.replaceChildren(label, item);
+		return;
+	}
 	let entItem = target.closest(".scene.item") as HTMLElement;
 	if (!entItem) {
 	    menu.style.display = "none";
@@ -299,10 +422,170 @@ entryList.oncontextmenu = async function(ev) {
 	let label = document.createElement("div");
 	label.classList.add("contextMenuLabel");
 	label.innerText = elem + " / " + ver;
+	// publish asks a comment, which is not the same as the version note.
+	let publishInput = document.createElement("input");
+	publishInput.classList.add("contextMenuPublishInput");
+	publishInput.placeholder = "publish with comment";
+	publishInput.onkeydown = async function(ev) {
+		ev.stopPropagation();
+		if (ev.code != "Enter") {
+			return;
+		}
+		try {
+			await App.Publish(app.Path, elem, ver, prog, publishInput.value.trim());
+			menu.style.display = "none";
+			log("published: " + elem + " / " + ver);
+			await App.ReloadEntry();
+			redrawAll();
+		} catch (err: any) {
+			logError(err);
+		}
+	}
+	let versionUp = newContextMenuItem("versionUp", "version up", elem, ver, prog);
+	let archive = newContextMenuItem("archive", "archive", elem, ver, prog);
+	let archiveOld = newContextMenuItem("archiveOld", "archive old versions", elem, ver, prog);
+	let deleteVersion = newContextMenuItem("deleteVersion", "delete version", elem, ver, prog);
+	let deleteElement = newContextMenuItem("deleteElement", "delete element", elem, ver, prog);
+	let renameInput = document.createElement("input");
+	renameInput.classList.add("contextMenuRenameInput");
+	renameInput.placeholder = "rename element";
+	renameInput.value = elem;
+	renameInput.onkeydown = async function(ev) {
+		ev.stopPropagation();
+		if (ev.code != "Enter") {
+			return;
+		}
+		try {
+			await App.RenameElement(app.Path, elem, prog, renameInput.value);
+			menu.style.display = "none";
+			await App.ReloadEntry();
+			redrawAll();
+		} catch (err: any) {
+			logError(err);
+		}
+	}
+	let noteInput = document.createElement("input");
+	noteInput.classList.add("contextMenuNoteInput");
+	noteInput.placeholder = "note";
+	// the menu is still useful without the note, when it couldn't be read.
+	let hasNote = true;
+	try {
+		noteInput.value = await App.VersionNote(app.Path, elem, ver, prog);
+	} catch (err: any) {
+		hasNote = false;
+		logError(err);
+	}
+	noteInput.onkeydown = async function(ev) {
+		ev.stopPropagation();
+		if (ev.code != "Enter") {
+			return;
+		}
+		try {
+			await App.SetVersionNote(app.Path, elem, ver, prog, noteInput.value);
+			menu.style.display = "none";
+			await App.ReloadEntry();
+			redrawAll();
+		} catch (err: any) {
+			logError(err);
+		}
+	}
+	let versionUpInput = document.createElement("input");
+	versionUpInput.classList.add("contextMenuVersionUpInput");
+	versionUpInput.placeholder = "version up with note";
+	versionUpInput.onkeydown = async function(ev) {
+		ev.stopPropagation();
+		if (ev.code != "Enter") {
+			return;
+		}
+		// alt+enter versions up the scene without launching the program.
+		let launch = !(ev.altKey || ev.metaKey);
+		try {
+			let newVer = await App.VersionUp(app.Path, elem, ver, prog, versionUpInput.value, launch);
+			menu.style.display = "none";
+			log("versioned up: " + elem + " / " + newVer);
+			await App.ReloadEntry();
+			redrawAll();
+		} catch (err: any) {
+			logError(err);
+		}
+	}
+	let copyInput = document.createElement("input");
+	copyInput.classList.add("contextMenuCopyInput");
+	copyInput.placeholder = "copy to part path";
+	copyInput.onkeydown = async function(ev) {
+		ev.stopPropagation();
+		if (ev.code != "Enter") {
+			return;
+		}
+		// alt+enter copies the element without launching the program.
+		let launch = !(ev.altKey || ev.metaKey);
+		try {
+			let dst = copyInput.value.trim();
+			let newVer = await App.CopyElement(app.Path, elem, ver, prog, dst, elem, "", launch);
+			menu.style.display = "none";
+			log("copied: " + dst + " / " + elem + " / " + newVer);
+		} catch (err: any) {
+			logError(err);
+		}
+	}
+	let items: HTMLElement[] = [label, publishInput, versionUp, versionUpInput];
+	if (hasNote) {
+		items.push(noteInput);
+	}
+	items.push(renameInput, copyInput, archive, archiveOld, deleteVersion, deleteElement);
+	menu.replaceChildren(...items);
+}
+
+function newContextMenuItem(action: string, text: string, elem: string, ver: string, prog: string): HTMLElement {
 	let item = document.createElement("div");
 	item.classList.add("contextMenuItem");
-	item.innerText = "publish";
-	menu.replaceChildren(label, item);
+	item.dataset.action = action;
+	item.dataset.elem = elem;
+	item.dataset.ver = ver;
+	item.dataset.prog = prog;
+	item.innerText = text;
+	return item;
+}
+
+let currentEntry = querySelector("#currentEntry");
+
+currentEntry.oncontextmenu = async function(ev) {
+	ev.preventDefault();
+	let menu = querySelector("#contextMenu");
+	menu.style.left = ev.pageX + "px";
+	menu.style.top = ev.pageY + "px";
+	menu.style.display = "flex";
+	let app = await App.State();
+	let label = document.createElement("div");
+	label.classList.add("contextMenuLabel");
+	label.innerText = app.Path;
+	let fromFile = newContextMenuItem("thumbnailFromFile", "thumbnail from file", "", "", "");
+	let fromClipboard = newContextMenuItem("thumbnailFromClipboard", "thumbnail from clipboard", "", "", "");
+	menu.replaceChildren(label, fromFile, fromClipboard);
+}
+
+// clipboardImage reads an image from the clipboard as a data url.
+async function clipboardImage(): Promise<string> {
+	let items = await navigator.clipboard.read();
+	for (let item of items) {
+		for (let typ of item.types) {
+			if (!typ.startsWith("image/")) {
+				continue;
+			}
+			let blob = await item.getType(typ);
+			return new Promise(function(resolve, reject) {
+				let reader = new FileReader();
+				reader.onload = function() {
+					resolve(reader.result as string);
+				}
+				reader.onerror = function() {
+					reject(reader.error);
+				}
+				reader.readAsDataURL(blob);
+			});
+		}
+	}
+	throw "no image in the clipboard";
 }
 
 let contextMenu = querySelector("#contextMenu");
@@ -340,6 +623,21 @@ window.onchange = async function(ev) {
 			logError(err);
 		}
 	}
+	let sortByTimeCheckBox = closest(target, "#sortByTimeCheckBox");
+	if (sortByTimeCheckBox) {
+		try {
+			let checkbox = sortByTimeCheckBox as HTMLInputElement;
+			await App.SetSortVersionsByTime(checkbox.checked);
+			await App.ReloadEntry();
+		} catch (err) {
+			logError(err);
+		}
+		try {
+			redrawAll();
+		} catch (err: any) {
+			logError(err);
+		}
+	}
 }
 
 window.onkeydown = async function(ev) {
@@ -417,19 +715,21 @@ window.onkeydown = async function(ev) {
 	}
 
 	let target = (<HTMLElement> ev.target);
-	let newElementFieldInput = closest(target, ".newElementFieldInput");
-	if (newElementFieldInput) {
-		let input = newElementFieldInput as HTMLInputElement;
+	let newElementField = closest(target, ".newElementField");
+	if (newElementField) {
 		let app = await App.State();
 		let oninput = function() {
 			if (ev.code != "Enter") {
 				return;
 			}
-			let field = closest(input, ".newElementField");
+			let field = newElementField;
 			let prog = field.dataset.prog as string;
-			let name = input.value as string;
+			let name = (field.querySelector(".newElementFieldInput") as HTMLInputElement).value;
+			let note = (field.querySelector(".newElementFieldNote") as HTMLInputElement).value;
 			field.classList.add("hidden");
-			App.NewElement(app.Path, name, prog).then(async function() {
+			// alt+enter creates the element without launching the program.
+			let launch = !(ev.altKey || ev.metaKey);
+			App.NewElement(app.Path, name, prog, note, launch).then(async function() {
 				await App.ReloadUserSetting();
 				await App.ReloadEntry();
 			}).then(redrawAll).catch(logError);
@@ -688,6 +988,7 @@ async function redrawAll(): Promise<void> {
 		redrawCurrentEntry(app);
 		redrawEntryList(app);
 		redrawInfoArea(app).catch(logError);
+		redrawNewEntryBar(app).catch(logError);
 		redrawProgramsBar(app);
 		redrawRecentPaths(app);
 	} catch (err) {
@@ -722,17 +1023,22 @@ function redrawLoginArea(app: any) {
 
 async function redrawOptionBar(app: any) {
 	let assignedCheckBox = querySelector("#assignedCheckBox") as HTMLInputElement;
+	let sortByTimeCheckBox = querySelector("#sortByTimeCheckBox") as HTMLInputElement;
 	let reloadAssignedButton = querySelector("#reloadAssignedButton");
 	let openDirButton = querySelector("#openCurrentDir");
 	if (app.User == "") {
 		assignedCheckBox.disabled = true;
 		assignedCheckBox.checked = false;
+		sortByTimeCheckBox.disabled = true;
+		sortByTimeCheckBox.checked = false;
 		reloadAssignedButton.classList.add("disabled");
 		openDirButton.dataset.path = "";
 		return;
 	}
 	assignedCheckBox.disabled = false;
 	assignedCheckBox.checked = app.Options.AssignedOnly;
+	sortByTimeCheckBox.disabled = false;
+	sortByTimeCheckBox.checked = app.Options.SortVersionsByTime;
 	reloadAssignedButton.classList.remove("disabled");
 	await refreshOpenDirButton(openDirButton, app.Path);
 }
@@ -840,10 +1146,108 @@ function redrawEntryList(app: any) {
 				scene.dataset.prog = e.Program;
 				scene.dataset.ver = v.Name;
 				scene.innerText = v.Name;
+				let info = document.createElement("span");
+				info.classList.add("versionInfo");
+				let modTime = new Date(v.ModTime);
+				info.innerText = modTime.toLocaleString() + " " + v.Owner + " " + formatSize(v.Size);
+				scene.append(info);
+				if (v.Published) {
+					let published = document.createElement("span");
+					published.classList.add("versionPublished");
+					published.innerText = "published";
+					// history is loaded only when the user wants to see it.
+					published.onmouseenter = async function() {
+						if (published.title != "") {
+							return;
+						}
+						try {
+							let recs = await App.PublishHistory(app.Path);
+							let lines = [];
+							for (let r of recs) {
+								if (r.Elem != e.Name || r.Program != e.Program || r.Version != v.Name) {
+									continue;
+								}
+								let line = new Date(r.At).toLocaleString() + " " + r.User;
+								if (r.Comment != "") {
+									line += ": " + r.Comment;
+								}
+								lines.push(line);
+							}
+							published.title = lines.join("\n");
+						} catch (err: any) {
+							logError(err);
+						}
+					};
+					scene.append(published);
+				}
+				if (v.Problems && v.Problems.length != 0) {
+					let problem = document.createElement("span");
+					problem.classList.add("versionProblem");
+					problem.innerText = v.Problems.join(", ");
+					scene.append(problem);
+				}
+				if (v.Lock) {
+					let lock = document.createElement("span");
+					lock.classList.add("versionLock");
+					lock.innerText = "opened by " + v.Lock.User + "@" + v.Lock.Host;
+					scene.append(lock);
+				}
+				if (v.Note != "") {
+					scene.title = v.Note;
+				}
 				elem.append(scene);
 			}
 			children.push(elem);
 		}
+		if (app.Unmanaged.length != 0) {
+			let label = document.createElement("div");
+			label.classList.add("unmanagedLabel");
+			label.innerText = "unmanaged";
+			children.push(label);
+		}
+		for (let u of app.Unmanaged) {
+			let div = document.createElement("div");
+			div.classList.add("unmanaged");
+			div.dataset.path = u.Path;
+			div.innerText = u.Name;
+			let reason = document.createElement("span");
+			reason.classList.add("unmanagedReason");
+			reason.innerText = u.Reason;
+			div.append(reason);
+			children.push(div);
+		}
+		if (app.Outputs.length != 0) {
+			let label = document.createElement("div");
+			label.classList.add("outputsLabel");
+			label.innerText = "outputs";
+			children.push(label);
+		}
+		for (let o of app.Outputs) {
+			for (let v of o.Versions) {
+				for (let s of v.Sequences) {
+					let seq = document.createElement("div");
+					seq.classList.add("sequence");
+					seq.dataset.path = s.Path;
+					seq.dataset.canThumbnail = String(s.CanThumbnail);
+					let name = s.Path.split("/").pop() as string;
+					let info = o.Name + " / " + v.Name + " / " + name;
+					if (s.Frames != 0) {
+						info += " [" + s.Start + "-" + s.End + "]";
+					}
+					if (s.MissingFrames != 0) {
+						seq.classList.add("incomplete");
+						info += " missing " + s.MissingFrames + " frames";
+						let missing = [];
+						for (let r of s.Missing) {
+							missing.push(r.Start == r.End ? String(r.Start) : r.Start + "-" + r.End);
+						}
+						seq.title = "missing: " + missing.join(" ");
+					}
+					seq.innerText = info;
+					children.push(seq);
+				}
+			}
+		}
 	} else {
 		for (let ent of app.Entries) {
 			let div = document.createElement("div") as HTMLElement;
@@ -868,6 +1272,16 @@ function redrawEntryList(app: any) {
 	entryList.replaceChildren(...children);
 }
 
+function formatSize(size: number): string {
+	let units = ["B", "KB", "MB", "GB", "TB"];
+	let i = 0;
+	while (size >= 1024 && i < units.length - 1) {
+		size /= 1024;
+		i++;
+	}
+	return size.toFixed(i == 0 ? 0 : 1) + units[i];
+}
+
 function setSelected(fallbackSelection: boolean) {
 	let entryList = document.querySelector("#entryList") as HTMLElement;
 	let firstItem = entryList.querySelector(".item:not(.hidden)") as HTMLElement;
@@ -916,6 +1330,69 @@ async function onclickElement(div: HTMLElement) {
 	}
 }
 
+// redrawNewEntryBar shows inputs to create a sub entry, when the entry can have sub entries.
+async function redrawNewEntryBar(app: any) {
+	let bar = querySelector("#newEntryBar");
+	bar.replaceChildren();
+	bar.classList.add("hidden");
+	if (!app.User || app.AtLeaf) {
+		return;
+	}
+	let types = await App.SubEntryTypes(app.Path);
+	if (types.length == 0) {
+		return;
+	}
+	let typeSelect = document.createElement("select");
+	typeSelect.id = "newEntryTypeSelect";
+	for (let t of types) {
+		let opt = document.createElement("option");
+		opt.value = t;
+		opt.innerText = t;
+		typeSelect.append(opt);
+	}
+	let tmplSelect = document.createElement("select");
+	tmplSelect.id = "newEntryTemplateSelect";
+	let fillTemplates = async function() {
+		let tmpls = await App.EntryTemplates(app.Path, typeSelect.value);
+		let opts = [];
+		let none = document.createElement("option");
+		none.value = "";
+		none.innerText = "(no template)";
+		opts.push(none);
+		for (let t of tmpls) {
+			let opt = document.createElement("option");
+			opt.value = t;
+			opt.innerText = t;
+			opts.push(opt);
+		}
+		tmplSelect.replaceChildren(...opts);
+		tmplSelect.classList.toggle("hidden", tmpls.length == 0);
+	}
+	typeSelect.onchange = function() {
+		fillTemplates().catch(logError);
+	}
+	await fillTemplates();
+	let nameInput = document.createElement("input");
+	nameInput.id = "newEntryNameInput";
+	nameInput.placeholder = "new entry name";
+	nameInput.onkeydown = async function(ev) {
+		ev.stopPropagation();
+		if (ev.code != "Enter") {
+			return;
+		}
+		try {
+			let path = await App.NewEntry(app.Path, nameInput.value, typeSelect.value, tmplSelect.value);
+			log("created: " + path);
+		} catch (err: any) {
+			logError(err);
+			return;
+		}
+		redrawAll();
+	}
+	bar.append(typeSelect, tmplSelect, nameInput);
+	bar.classList.remove("hidden");
+}
+
 async function redrawNewElementButtons(app: any) {
 	let elemBtns = querySelector("#newElementButtons");
 	let children = [];
@@ -989,6 +1466,109 @@ function addEntryInfoDiv(ent: any) {
 	return div
 }
 
+// editProperty replaces the value div with an input fit for the property type.
+// The value is sent to the host when enter is pressed, or discarded with escape.
+// Text properties are multi line, so they need ctrl+enter instead.
+async function editProperty(ent: any, p: any, valueDiv: HTMLElement) {
+	let app = await App.State();
+	let input: any;
+	if (p.Name == "status") {
+		let sel = document.createElement("select");
+		let stats = await App.PossibleStatus(ent.Type);
+		for (let s of ["", ...stats]) {
+			let opt = document.createElement("option");
+			opt.value = s;
+			opt.innerText = s ? s : "(none)";
+			sel.append(opt);
+		}
+		sel.value = p.Value;
+		sel.onchange = function() {
+			commit();
+		}
+		input = sel;
+	} else if (p.Type == "text") {
+		input = document.createElement("textarea");
+		input.value = p.Value;
+	} else {
+		input = document.createElement("input");
+		input.value = p.Value;
+		if (p.Type == "int") {
+			input.type = "number";
+		} else if (p.Type == "date") {
+			input.type = "date";
+			input.value = p.Value.replaceAll("/", "-");
+		} else if (p.Type == "user") {
+			// suggest users already known to the app.
+			let users = new Set<string>([app.User]);
+			for (let e of [...app.ParentEntries, app.Entry, ...app.Entries]) {
+				for (let name in e.Property) {
+					let ep = e.Property[name];
+					if (ep.Type == "user" && ep.Value) {
+						users.add(ep.Value);
+					}
+				}
+			}
+			let list = document.createElement("datalist");
+			list.id = "propertyUserList";
+			for (let u of Array.from(users).sort()) {
+				let opt = document.createElement("option");
+				opt.value = u;
+				list.append(opt);
+			}
+			input.setAttribute("list", list.id);
+			valueDiv.replaceChildren(list);
+		}
+	}
+	input.classList.add("propertyEditor");
+	let commit = async function() {
+		try {
+			await App.UpdateProperty(ent.Path, p.Name, input.value);
+			log("updated: " + ent.Path + "." + p.Name);
+		} catch (err: any) {
+			logError(err);
+		}
+		redrawAll();
+	}
+	input.onkeydown = function(ev: KeyboardEvent) {
+		ev.stopPropagation();
+		if (ev.code == "Escape") {
+			App.State().then(redrawInfoArea).catch(logError);
+			return;
+		}
+		if (ev.code != "Enter") {
+			return;
+		}
+		if (p.Type == "text" && !(ev.ctrlKey || ev.metaKey)) {
+			return;
+		}
+		ev.preventDefault();
+		commit();
+	}
+	input.onclick = function(ev: MouseEvent) {
+		ev.stopPropagation();
+	}
+	if (p.Type == "user") {
+		valueDiv.append(input);
+	} else {
+		valueDiv.replaceChildren(input);
+	}
+	input.focus();
+}
+
+// editStatus shows a status selector next to the status dot of an entry.
+function editStatus(ent: any, statusProp: any, dot: HTMLElement) {
+	let title = closest(dot, ".title");
+	let editor = title.querySelector(".statusEditor") as HTMLElement;
+	if (editor) {
+		editor.remove();
+		return;
+	}
+	editor = document.createElement("div");
+	editor.classList.add("statusEditor");
+	dot.after(editor);
+	editProperty(ent, statusProp, editor).catch(logError);
+}
+
 async function redrawInfoArea(app: any) {
 	let area = querySelector("#infoArea");
 	if (!app.User) {
@@ -1055,6 +1635,10 @@ async function redrawInfoArea(app: any) {
 			dot.style.backgroundColor = color;
 			dot.style.border = "1px solid " + color + "bb";
 			dot.classList.remove("hidden");
+			dot.onclick = function(ev) {
+				ev.stopPropagation();
+				editStatus(ent, statusProp, dot);
+			}
 		}
 		let propsDiv = document.createElement("div");
 		propsDiv.classList.add("entryProperties");
@@ -1109,12 +1693,16 @@ async function redrawInfoArea(app: any) {
 					if (p.Type == "entry_link") {
 						d.classList.add("entryLink");
 					} else {
-						if (l.startsWith("/")) {
+						if (l.startsWith("/") || /^[A-Za-z]:[\\/]/.test(l) || l.startsWith("\\\\")) {
 							d.classList.add("pathText");
 						}
 					}
 					valueDiv.append(d);
 				}
+				valueDiv.title = "double click to edit";
+				valueDiv.ondblclick = function() {
+					editProperty(ent, p, valueDiv).catch(logError);
+				}
 				propDiv.append(nameDiv, valueDiv);
 				children.push(propDiv);
 			}
@@ -1180,16 +1768,149 @@ async function redrawInfoArea(app: any) {
 				dot.style.backgroundColor = color+"dd";
 				dot.style.border = "1px solid " + color;
 				dot.classList.remove("hidden");
+				dot.onclick = function(ev) {
+					ev.stopPropagation();
+					editStatus(ent, statusProp, dot);
+				}
 			}
 			let info = entDiv.querySelector(".titleInfo") as HTMLElement;
 			info.innerText = ent.Property["assignee"].Eval;
 			children.push(entDiv);
 		}
 	}
+	try {
+		children.push(await newNotesDiv(app));
+	} catch (err) {
+		logError(err);
+	}
 	area.replaceChildren(...children);
 }
 
+// newNotesDiv shows notes of the current entry and its parents,
+// with an input to post a new note or reply to one.
+async function newNotesDiv(app: any): Promise<HTMLElement> {
+	let div = document.createElement("div");
+	div.classList.add("notes");
+	let entNotes = await App.EntryNotes(app.Path);
+	let replyTo = 0;
+	let input = document.createElement("textarea");
+	let replyLabel = document.createElement("div");
+	replyLabel.classList.add("noteReplyLabel", "hidden");
+	replyLabel.onclick = function() {
+		replyTo = 0;
+		replyLabel.classList.add("hidden");
+	}
+	for (let en of entNotes) {
+		let label = document.createElement("div");
+		label.classList.add("notesLabel", "pathLink", "link");
+		label.dataset.path = en.Path;
+		label.innerText = en.Path;
+		div.append(label);
+		let noteDivs = new Map<number, HTMLElement>();
+		for (let n of en.Notes) {
+			let noteDiv = document.createElement("div");
+			noteDiv.classList.add("note");
+			let header = document.createElement("div");
+			header.classList.add("noteHeader");
+			header.innerText = n.User + " · " + new Date(n.When).toLocaleString();
+			if (n.Ver) {
+				let ref = document.createElement("span");
+				ref.classList.add("noteRef");
+				ref.innerText = (n.Elem ? n.Elem : "[main]") + " / " + n.Ver + " (" + n.Program + ")";
+				if (en.Path == app.Path) {
+					ref.classList.add("link");
+					ref.title = "open the version";
+					ref.onclick = function() {
+						App.OpenScene(app.Path, n.Elem, n.Ver, n.Program).catch(logError);
+					}
+				}
+				header.append(ref);
+			}
+			if (en.Path == app.Path) {
+				let reply = document.createElement("span");
+				reply.classList.add("noteReplyButton", "link");
+				reply.innerText = "reply";
+				reply.onclick = function() {
+					replyTo = n.ID;
+					replyLabel.innerText = "reply to " + n.User + " #" + n.ID + " (click to cancel)";
+					replyLabel.classList.remove("hidden");
+					input.focus();
+				}
+				header.append(reply);
+			}
+			let text = document.createElement("div");
+			text.classList.add("noteText");
+			text.innerText = n.Text;
+			noteDiv.append(header, text);
+			let parent = noteDivs.get(n.ReplyTo);
+			if (parent) {
+				noteDiv.classList.add("reply");
+				parent.append(noteDiv);
+			} else {
+				div.append(noteDiv);
+			}
+			noteDivs.set(n.ID, noteDiv);
+		}
+	}
+	let attach = document.createElement("input");
+	attach.type = "checkbox";
+	attach.id = "noteAttachVersion";
+	let attachLabel = document.createElement("label");
+	attachLabel.htmlFor = attach.id;
+	attachLabel.innerText = "attach selected version";
+	let attachBar = document.createElement("div");
+	attachBar.classList.add("noteAttachBar");
+	if (app.AtLeaf) {
+		attachBar.append(attach, attachLabel);
+	}
+	input.classList.add("noteInput");
+	input.placeholder = "leave a note (ctrl+enter to post)";
+	input.onkeydown = async function(ev) {
+		ev.stopPropagation();
+		if (ev.code != "Enter" || !(ev.ctrlKey || ev.metaKey)) {
+			return;
+		}
+		ev.preventDefault();
+		let elem = "";
+		let ver = "";
+		let prog = "";
+		if (attach.checked) {
+			let sel = document.querySelector<HTMLElement>(".item.selected");
+			if (!sel || sel.dataset.prog == undefined) {
+				logError("select a version to attach");
+				return;
+			}
+			elem = sel.dataset.elem as string;
+			ver = sel.dataset.ver as string;
+			prog = sel.dataset.prog as string;
+			try {
+				if (!ver) {
+					ver = await App.LastVersionOfElement(app.Path, elem, prog);
+				}
+			} catch (err) {
+				logError(err);
+				return;
+			}
+		}
+		try {
+			await App.PostNote(app.Path, input.value, replyTo, elem, ver, prog);
+			log("note posted");
+		} catch (err) {
+			logError(err);
+			return;
+		}
+		redrawAll();
+	}
+	div.append(replyLabel, input, attachBar);
+	return div;
+}
+
 async function refreshOpenDirButton(btn: any, ent: any) {
+	btn.oncontextmenu = function(ev: MouseEvent) {
+		ev.preventDefault();
+		ev.stopPropagation();
+		showDirTemplateMenu(ev, ent).catch(logError);
+	}
 	let path = "";
 	try {
 		path = await App.Dir(ent);
@@ -1217,6 +1938,105 @@ async function refreshOpenDirButton(btn: any, ent: any) {
 	}
 }
 
+// showDiskUsage shows a disk usage report on top of the info area.
+// It will be gone when the info area is redrawn.
+function showDiskUsage(du: any) {
+	let area = querySelector("#infoArea");
+	let old = area.querySelector(".diskUsage");
+	if (old) {
+		old.remove();
+	}
+	let div = document.createElement("div");
+	div.classList.add("diskUsage");
+	let title = document.createElement("div");
+	title.classList.add("diskUsageTitle");
+	title.innerText = du.Path + ": " + formatSize(du.Size) + " in " + du.Files + " files";
+	title.title = "click to close";
+	title.onclick = function() {
+		div.remove();
+	}
+	div.append(title);
+	let addTable = function(label: string, sizes: any) {
+		let keys = Object.keys(sizes);
+		if (keys.length == 0) {
+			return;
+		}
+		// larger ones first.
+		keys.sort((a, b) => sizes[b] - sizes[a]);
+		let labelDiv = document.createElement("div");
+		labelDiv.classList.add("diskUsageLabel");
+		labelDiv.innerText = label;
+		div.append(labelDiv);
+		for (let k of keys) {
+			let row = document.createElement("div");
+			row.classList.add("diskUsageRow");
+			let name = document.createElement("div");
+			name.classList.add("diskUsageName");
+			name.innerText = k;
+			let size = document.createElement("div");
+			size.classList.add("diskUsageSize");
+			size.innerText = formatSize(sizes[k]);
+			row.append(name, size);
+			div.append(row);
+		}
+	}
+	addTable("entries", du.ByEntry);
+	addTable("elements", du.ByElement);
+	addTable("versions", du.ByVersion);
+	addTable("file types", du.ByType);
+	if (du.Errors.length != 0) {
+		let errDiv = document.createElement("div");
+		errDiv.classList.add("diskUsageErrors");
+		errDiv.innerText = du.Errors.length + " errors: " + du.Errors.slice(0, 5).join("\n");
+		div.append(errDiv);
+	}
+	area.prepend(div);
+}
+
+// showDirTemplateMenu previews directories those the directory template will create for the entry.
+async function showDirTemplateMenu(ev: MouseEvent, path: string) {
+	let menu = querySelector("#contextMenu");
+	menu.style.left = ev.pageX + "px";
+	menu.style.top = ev.pageY + "px";
+	let label = document.createElement("div");
+	label.classList.add("contextMenuLabel");
+	label.innerText = path;
+	let children = [label];
+	// disk usage doesn't need the directory template, the menu is shown even if it cannot be planned.
+	try {
+		let plan = await App.DirTemplatePlan(path);
+		if (plan.length == 0) {
+			let div = document.createElement("div");
+			div.classList.add("plannedDir");
+			div.innerText = "all directories exist";
+			children.push(div);
+		}
+		for (let d of plan) {
+			let div = document.createElement("div");
+			div.classList.add("plannedDir");
+			div.innerText = d.Path + " (" + d.Mode + ")";
+			children.push(div);
+		}
+		if (plan.length != 0) {
+			let item = newContextMenuItem("applyDirTemplate", "create directories", "", "", "");
+			item.dataset.path = path;
+			children.push(item);
+		}
+	} catch (err: any) {
+		let div = document.createElement("div");
+		div.classList.add("plannedDir");
+		div.innerText = "directory template: " + err;
+		children.push(div);
+	}
+	let usage = newContextMenuItem("diskUsage", "disk usage", "", "", "");
+	usage.dataset.path = path;
+	let usageSubs = newContextMenuItem("diskUsageSubs", "disk usage with all sub entries", "", "", "");
+	usageSubs.dataset.path = path;
+	children.push(usage, usageSubs);
+	menu.replaceChildren(...children);
+	menu.style.display = "flex";
+}
+
 function toggleAddProgramLinkPopup() {
 	let popup = querySelector("#addProgramLinkPopup");
 	let hidden = popup.classList.contains("hidden");
@@ -1290,6 +2110,10 @@ function addNewElementField(prog: string) {
 	let span = document.createElement("span");
 	span.innerText = " (" + prog + ")";
 	field.append(span);
+	let note = document.createElement("input");
+	note.classList.add("newElementFieldNote");
+	note.placeholder = "note";
+	field.append(note);
 	input.focus();
 }
 
@@ -4,8 +4,10 @@ go 1.18
 
 require (
 	github.com/BurntSushi/toml v1.1.0
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/imagvfx/forge v0.0.0-20220904132550-0e2736a1f594
 	github.com/wailsapp/wails/v2 v2.4.0
+	golang.org/x/image v0.5.0
 )
 
 require (
@@ -30,7 +32,6 @@ require (
 	github.com/wailsapp/mimetype v1.4.1 // indirect
 	golang.org/x/crypto v0.1.0 // indirect
 	golang.org/x/exp v0.0.0-20220303212507-bbda1eaf7a17 // indirect
-	golang.org/x/image v0.5.0 // indirect
 	golang.org/x/net v0.7.0 // indirect
 	golang.org/x/sys v0.5.0 // indirect
 	golang.org/x/text v0.7.0 // indirect
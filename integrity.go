@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	wails "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// truncateRatio is how smaller a version could be than the previous version,
+// before it is suspected to be truncated.
+const truncateRatio = 10
+
+// fileChecksum returns sha256 checksum of a file.
+func fileChecksum(f string) (string, error) {
+	r, err := os.Open(f)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sceneChecksum is a checksum of a scene, which is valid while the scene has the size and modification time.
+// Err is set instead of Sum when the scene couldn't be read.
+type sceneChecksum struct {
+	Sum     string
+	Err     string
+	Size    int64
+	ModTime time.Time
+}
+
+// checksumCache remembers checksums computed by the app.
+// Checksums are also cached in meta of scenes, but writing meta could fail. (ex: read only directory)
+type checksumCache struct {
+	lock   sync.Mutex
+	sums   map[string]*sceneChecksum
+	flight flightGroup
+}
+
+// get returns a known checksum of the version, from the cache or the version's meta.
+func (c *checksumCache) get(v *Version) (*sceneChecksum, bool) {
+	c.lock.Lock()
+	sum := c.sums[v.Scene]
+	c.lock.Unlock()
+	if sum != nil && sum.Size == v.Size && sum.ModTime.Equal(v.ModTime) {
+		return sum, true
+	}
+	meta := v.meta
+	if meta != nil && meta.Checksum != "" && meta.ChecksumSize == v.Size && meta.ChecksumModTime.Equal(v.ModTime) {
+		return &sceneChecksum{Sum: meta.Checksum, Size: v.Size, ModTime: v.ModTime}, true
+	}
+	return nil, false
+}
+
+// compute computes checksum of the version and remembers it.
+// Caching it to the scene's meta is best-effort.
+func (c *checksumCache) compute(v *Version) {
+	c.flight.Do(v.Scene, func() (interface{}, error) {
+		if _, ok := c.get(v); ok {
+			return nil, nil
+		}
+		sum := &sceneChecksum{Size: v.Size, ModTime: v.ModTime}
+		var err error
+		sum.Sum, err = fileChecksum(v.Scene)
+		if err != nil {
+			log.Printf("checksum: %v", err)
+			sum.Err = err.Error()
+		}
+		c.lock.Lock()
+		if c.sums == nil {
+			c.sums = make(map[string]*sceneChecksum)
+		}
+		c.sums[v.Scene] = sum
+		c.lock.Unlock()
+		if sum.Err != "" {
+			return nil, nil
+		}
+		err = updateSceneMeta(v.Scene, func(meta *SceneMeta) {
+			meta.Checksum = sum.Sum
+			meta.ChecksumSize = sum.Size
+			meta.ChecksumModTime = sum.ModTime
+		})
+		if err != nil {
+			log.Printf("cache checksum: %v", err)
+		}
+		return nil, nil
+	})
+}
+
+// computeChecksums computes checksums of the versions of an entry in background,
+// then emits "checksumsChanged" event with the entry path.
+func (a *App) computeChecksums(path string, vers []Version) {
+	for i := range vers {
+		a.checksums.compute(&vers[i])
+	}
+	wails.EventsEmit(a.ctx, "checksumsChanged", path)
+}
+
+// hasMagic returns whether the file starts with magic.
+func hasMagic(f, magic string) (bool, error) {
+	r, err := os.Open(f)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	head := make([]byte, len(magic))
+	_, err = io.ReadFull(r, head)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(head, []byte(magic)), nil
+}
+
+// checkVersions checks integrity of versions of an element, and records the problems to them.
+// Versions should be sorted by their numbers, from the latest.
+// Checksums are needed when checksum is true, or the version is published,
+// since a published version should be checked whether it has changed after that.
+// Checksums are not computed here as it takes long for large scenes, only known ones are used.
+// It returns versions those need checksums to be computed.
+func checkVersions(vers []Version, pg *Program, checksum bool, sums *checksumCache) []Version {
+	need := make([]Version, 0)
+	if pg.DirScene {
+		return need
+	}
+	for i := range vers {
+		v := &vers[i]
+		v.Problems = make([]string, 0)
+		if v.Size == 0 {
+			v.Problems = append(v.Problems, "empty file")
+			continue
+		}
+		if pg.Magic != "" {
+			ok, err := hasMagic(v.Scene, pg.Magic)
+			if err != nil {
+				v.Problems = append(v.Problems, "couldn't read file header: "+err.Error())
+			} else if !ok {
+				v.Problems = append(v.Problems, "invalid file header for "+pg.Name)
+			}
+		}
+		if i+1 < len(vers) {
+			prev := vers[i+1]
+			if v.Size*truncateRatio < prev.Size {
+				v.Problems = append(v.Problems, "much smaller than "+prev.Name+" ("+strconv.FormatInt(v.Size, 10)+" bytes)")
+			}
+		}
+		published := v.meta != nil && v.meta.PublishedChecksum != ""
+		if !checksum && !published {
+			continue
+		}
+		sum, ok := sums.get(v)
+		if !ok {
+			need = append(need, *v)
+			continue
+		}
+		if sum.Err != "" {
+			v.Problems = append(v.Problems, "couldn't compute checksum: "+sum.Err)
+			continue
+		}
+		v.Checksum = sum.Sum
+		if published && v.Checksum != v.meta.PublishedChecksum {
+			v.Problems = append(v.Problems, "changed after published")
+		}
+	}
+	return need
+}
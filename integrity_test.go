@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckVersions(t *testing.T) {
+	blender := &Program{Name: "blender", Magic: "BLENDER"}
+	cases := []struct {
+		label string
+		pg    *Program
+		// data of versions, from the latest.
+		data []string
+		// published is the published checksum of the latest version, if it is published.
+		published string
+		checksum  bool
+		// computed tells whether checksums are computed before the check.
+		computed bool
+		want     []string
+		wantNeed int
+	}{
+		{
+			label: "ok",
+			pg:    blender,
+			data:  []string{"BLENDER-v2", "BLENDER-v1"},
+			want:  []string{},
+		},
+		{
+			label: "empty",
+			pg:    blender,
+			data:  []string{"", "BLENDER-v1"},
+			want:  []string{"empty file"},
+		},
+		{
+			label: "invalid magic",
+			pg:    blender,
+			data:  []string{"MAYA-v2", "BLENDER-v1"},
+			want:  []string{"invalid file header for blender"},
+		},
+		{
+			label: "shorter than magic",
+			pg:    blender,
+			data:  []string{"BLEND"},
+			want:  []string{"invalid file header for blender"},
+		},
+		{
+			label: "no magic",
+			pg:    &Program{Name: "nuke"},
+			data:  []string{"MAYA-v2"},
+			want:  []string{},
+		},
+		{
+			label: "truncated",
+			pg:    blender,
+			data:  []string{"BLENDER", "BLENDER" + string(make([]byte, 100))},
+			want:  []string{"much smaller than v001 (7 bytes)"},
+		},
+		{
+			label:    "checksum not computed",
+			pg:       blender,
+			data:     []string{"BLENDER-v1"},
+			checksum: true,
+			want:     []string{},
+			wantNeed: 1,
+		},
+		{
+			label:    "checksum computed",
+			pg:       blender,
+			data:     []string{"BLENDER-v1"},
+			checksum: true,
+			computed: true,
+			want:     []string{},
+		},
+		{
+			label:     "published not computed",
+			pg:        blender,
+			data:      []string{"BLENDER-v1"},
+			published: "sum",
+			want:      []string{},
+			wantNeed:  1,
+		},
+		{
+			label:     "changed after published",
+			pg:        blender,
+			data:      []string{"BLENDER-v1"},
+			published: "sum",
+			computed:  true,
+			want:      []string{"changed after published"},
+		},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		vers := make([]Version, len(c.data))
+		for i, data := range c.data {
+			num := len(c.data) - i
+			name := fmt.Sprintf("v%03d", num)
+			v := Version{
+				Name:  name,
+				Num:   num,
+				Scene: filepath.Join(dir, "a."+name+".blend"),
+			}
+			err := os.WriteFile(v.Scene, []byte(data), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = statVersion(&v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vers[i] = v
+		}
+		if c.published != "" {
+			vers[0].meta = &SceneMeta{PublishedChecksum: c.published}
+		}
+		sums := &checksumCache{}
+		if c.computed {
+			sums.compute(&vers[0])
+		}
+		need := checkVersions(vers, c.pg, c.checksum, sums)
+		if len(need) != c.wantNeed {
+			t.Fatalf("%s: got %d versions need checksums, want %d", c.label, len(need), c.wantNeed)
+		}
+		got := vers[0].Problems
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s: got problems %q, want %q", c.label, got, c.want)
+		}
+		if c.computed {
+			want, err := fileChecksum(vers[0].Scene)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vers[0].Checksum != want {
+				t.Fatalf("%s: got checksum %s, want %s", c.label, vers[0].Checksum, want)
+			}
+		}
+	}
+}
+
+func TestCheckVersionsDirScene(t *testing.T) {
+	vers := []Version{{Name: "v001", Scene: t.TempDir()}}
+	need := checkVersions(vers, &Program{Name: "project", DirScene: true}, true, &checksumCache{})
+	if len(need) != 0 {
+		t.Fatalf("directory scenes shouldn't need checksums: %v", need)
+	}
+	if vers[0].Problems != nil {
+		t.Fatalf("directory scenes shouldn't be checked: %v", vers[0].Problems)
+	}
+}
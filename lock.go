@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockExpire is how long a lock from other host is valid.
+// The app cannot check whether a process of other host is still alive.
+const lockExpire = 24 * time.Hour
+
+// SceneLock tells who has opened a scene with the app.
+// It is saved as a json file in the '.canal' directory next to the scene.
+type SceneLock struct {
+	User string
+	Host string
+	PID  int
+	At   time.Time
+}
+
+// Stale returns true if the process which opened the scene is gone,
+// or the lock is too old when it's from other host.
+func (l *SceneLock) Stale() bool {
+	return l.staleAfter(lockExpire)
+}
+
+// staleAfter is Stale with expiry for a lock from other host.
+func (l *SceneLock) staleAfter(expire time.Duration) bool {
+	host, _ := os.Hostname()
+	if l.Host == host {
+		return !processAlive(l.PID)
+	}
+	return time.Since(l.At) > expire
+}
+
+// sceneLockFile returns path of the lock file for a scene.
+func sceneLockFile(scene string) string {
+	return canalFile(scene, ".lock")
+}
+
+// readSceneLock reads lock of a scene.
+// It returns nil without an error when the scene isn't locked.
+func readSceneLock(scene string) (*SceneLock, error) {
+	data, err := os.ReadFile(sceneLockFile(scene))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	lock := &SceneLock{}
+	err = json.Unmarshal(data, lock)
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// activeSceneLock returns lock of a scene if it isn't stale.
+// It doesn't remove a stale lock, as it is called while listing versions which should be read only.
+// Stale locks are removed by removeStaleSceneLock when the scene is opened.
+func activeSceneLock(scene string) (*SceneLock, error) {
+	lock, err := readSceneLock(scene)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil || lock.Stale() {
+		return nil, nil
+	}
+	return lock, nil
+}
+
+// removeStaleSceneLock removes lock of a scene if it is stale.
+func removeStaleSceneLock(scene string) error {
+	lock, err := readSceneLock(scene)
+	if err != nil {
+		return err
+	}
+	if lock == nil || !lock.Stale() {
+		return nil
+	}
+	err = os.Remove(sceneLockFile(scene))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// lockScene locks a scene for the process.
+// The lock is valid while the process is alive, so it doesn't work for a launcher
+// that starts the program and exits right away. (ex: open -a on macOS)
+// Programs with those launchers should set Program.NoLock.
+func lockScene(scene, user string, pid int) error {
+	host, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	lock := &SceneLock{
+		User: user,
+		Host: host,
+		PID:  pid,
+		At:   time.Now(),
+	}
+	data, err := json.MarshalIndent(lock, "", "\t")
+	if err != nil {
+		return err
+	}
+	f := sceneLockFile(scene)
+	err = os.MkdirAll(filepath.Dir(f), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f, data, 0644)
+}
+
+// unlockScene unlocks a scene, only when it is locked by the process of this host.
+// Others might open the scene after the process, and we shouldn't remove their lock.
+func unlockScene(scene string, pid int) error {
+	lock, err := readSceneLock(scene)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return nil
+	}
+	host, _ := os.Hostname()
+	if lock.Host != host || lock.PID != pid {
+		return nil
+	}
+	err = os.Remove(sceneLockFile(scene))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
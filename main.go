@@ -21,7 +21,29 @@ type Config struct {
 	Scene         string
 	Envs          []string
 	Dir           map[string]string
-	Programs      []*Program
+	// DirTemplate defines sub directories those will be created in Dir of an entry type.
+	DirTemplate map[string][]*DirTemplate
+	// AutoDirTemplate applies DirTemplate whenever the user enters an entry.
+	AutoDirTemplate bool
+	// Wrapper is a command template that will be prepended to
+	// CreateCmd and OpenCmd of programs, when its environs are defined.
+	Wrapper  []string
+	Programs []*Program
+	// Viewer is a program that opens output sequences.
+	Viewer *Program
+	// PathMap maps paths for another os to this os's,
+	// so the same environs work on every os.
+	PathMap []PathMap
+	// PathEnvs are names of environs those are paths, and mapped with PathMap.
+	// SCENE_DIR, OUTPUT_DIR, PUBLISH_DIR and ENTRY_TEMPLATE_ROOT are always mapped.
+	PathEnvs []string
+	// ThumbnailCacheSize is the maximum size of the thumbnail disk cache in MiB.
+	ThumbnailCacheSize int
+	// ThumbnailMaxAge is how long a cached thumbnail is used without checking the host. (ex: 1h)
+	ThumbnailMaxAge string
+	// ThumbnailConverter is a command that converts an image the app cannot decode (ex: exr)
+	// to a png file, for thumbnails. INPUT and OUTPUT environs are paths of the image and the png.
+	ThumbnailConverter []string
 }
 
 func mustReadConfig(config string) *Config {
@@ -30,6 +52,10 @@ func mustReadConfig(config string) *Config {
 	if err != nil {
 		log.Fatalf("couldn't decode config file: %s", config)
 	}
+	_, err = thumbnailMaxAge(cfg)
+	if err != nil {
+		log.Fatalf("invalid config file: %s: %v", config, err)
+	}
 	sort.Slice(cfg.Programs, func(i, j int) bool {
 		return cfg.Programs[i].Name < cfg.Programs[j].Name
 	})
@@ -50,11 +76,12 @@ func main() {
 
 	// Create application with options
 	err := wails.Run(&options.App{
-		Title:     "Canal",
-		Width:     1024,
-		Height:    768,
-		Assets:    assets,
-		OnStartup: app.startup,
+		Title:      "Canal",
+		Width:      1024,
+		Height:     768,
+		Assets:     assets,
+		OnStartup:  app.startup,
+		OnShutdown: app.shutdown,
 		Bind: []interface{}{
 			app,
 			&Elem{},
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	wails "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// archiveDirName is the name of directory where archived scenes are moved into.
+// It is hidden, so archived scenes won't be listed as elements.
+const archiveDirName = ".archive"
+
+// sceneDirOf returns the scene directory defined in environs.
+func sceneDirOf(env []string) (string, error) {
+	sceneDir := getEnv("SCENE_DIR", env)
+	if sceneDir == "" {
+		return "", fmt.Errorf("no scene directory information: check SCENE_DIR environ")
+	}
+	return filepath.Clean(evalEnvString(sceneDir, env)), nil
+}
+
+// checkInDir returns an error if the path is not inside of dir.
+func checkInDir(path, dir string) error {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("not in directory %s: %s", dir, path)
+	}
+	return nil
+}
+
+// findElem finds an element of a part, and returns it with the scene directory.
+func (a *App) findElem(path, elem, prog string) (*Elem, string, error) {
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return nil, "", err
+	}
+	sceneDir, err := sceneDirOf(env)
+	if err != nil {
+		return nil, "", err
+	}
+	elems, err := a.ListElements(path)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, e := range elems {
+		if e.Name == elem && e.Program == prog {
+			for _, v := range e.Versions {
+				err := checkInDir(v.Scene, sceneDir)
+				if err != nil {
+					return nil, "", err
+				}
+			}
+			return e, sceneDir, nil
+		}
+	}
+	return nil, "", &ElemNotExistError{elem: elem}
+}
+
+// findVersion finds a version of an element, and returns it with the scene directory.
+func (a *App) findVersion(path, elem, ver, prog string) (Version, string, error) {
+	e, sceneDir, err := a.findElem(path, elem, prog)
+	if err != nil {
+		return Version{}, "", err
+	}
+	for _, v := range e.Versions {
+		if v.Name == ver {
+			return v, sceneDir, nil
+		}
+	}
+	return Version{}, "", fmt.Errorf("version does not exist: %s %s", elem, ver)
+}
+
+// moveScene moves a scene with it's sidecar files, like meta and lock.
+// It fails if there is already a file at dst.
+func moveScene(src, dst string) error {
+	_, err := os.Stat(dst)
+	if err == nil {
+		return fmt.Errorf("file exists: %s", dst)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(src, dst)
+	if err != nil {
+		return err
+	}
+	for _, ext := range sceneSidecarExts {
+		f := canalFile(src, ext)
+		_, err = os.Stat(f)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		to := canalFile(dst, ext)
+		err = os.MkdirAll(filepath.Dir(to), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(f, to)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeScene removes a scene with it's sidecar files.
+func removeScene(scene string) error {
+	err := os.RemoveAll(scene)
+	if err != nil {
+		return err
+	}
+	for _, ext := range sceneSidecarExts {
+		err = os.Remove(canalFile(scene, ext))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNotLocked returns an error if any of the versions is opened by someone, so it shouldn't be moved or removed.
+// Stale locks are ignored.
+func checkNotLocked(vers []Version) error {
+	for _, v := range vers {
+		lock, err := activeSceneLock(v.Scene)
+		if err != nil {
+			return fmt.Errorf("read lock of %s: %v", v.Scene, err)
+		}
+		if lock != nil {
+			return fmt.Errorf("%s is opened by %s on %s", filepath.Base(v.Scene), lock.User, lock.Host)
+		}
+	}
+	return nil
+}
+
+// confirm asks the user a question, and returns true if the user answered yes.
+func (a *App) confirm(title, msg string) (bool, error) {
+	ans, err := wails.MessageDialog(a.ctx, wails.MessageDialogOptions{
+		Type:          wails.QuestionDialog,
+		Title:         title,
+		Message:       msg,
+		Buttons:       []string{"Yes", "No"},
+		DefaultButton: "No",
+		CancelButton:  "No",
+	})
+	if err != nil {
+		return false, err
+	}
+	return ans == "Yes", nil
+}
+
+// RenameElement renames all versions of an element, following the scene name rule of the part.
+// It doesn't rename anything when any of the new scenes already exists or any version is opened by someone,
+// and moves back renamed versions when it fails in the middle.
+func (a *App) RenameElement(path, elem, prog, newElem string) error {
+	newElem = strings.TrimSpace(newElem)
+	if newElem == elem {
+		return nil
+	}
+	if newElem == "" {
+		return fmt.Errorf("element name not specified")
+	}
+	if strings.ContainsAny(newElem, `/\`) {
+		return fmt.Errorf("element name cannot have '/' or '\\': %s", newElem)
+	}
+	e, sceneDir, err := a.findElem(path, elem, prog)
+	if err != nil {
+		return err
+	}
+	err = checkNotLocked(e.Versions)
+	if err != nil {
+		return err
+	}
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return err
+	}
+	query := evalEnvString(getEnv("SCENE_NAME_QUERY", env), env)
+	reName, err := regexp.Compile("^" + query + "$")
+	if err != nil {
+		return err
+	}
+	renames, err := planElementRename(e.Versions, newElem, sceneDir, reName, func(ver string) (string, error) {
+		return a.SceneFile(path, newElem, ver, prog)
+	})
+	if err != nil {
+		return err
+	}
+	return moveScenes(renames)
+}
+
+// sceneMove is a scene that will be moved from a path to another.
+type sceneMove struct {
+	from string
+	to   string
+}
+
+// planElementRename returns scene moves to rename the versions to newElem.
+// sceneFile returns the new scene path of a version.
+// It returns an error when any of the new scenes exists, is out of sceneDir,
+// or wouldn't be found as the same version of newElem with reName.
+func planElementRename(vers []Version, newElem, sceneDir string, reName *regexp.Regexp, sceneFile func(ver string) (string, error)) ([]sceneMove, error) {
+	moves := make([]sceneMove, 0, len(vers))
+	for _, v := range vers {
+		scene, err := sceneFile(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		err = checkInDir(scene, sceneDir)
+		if err != nil {
+			return nil, err
+		}
+		// the renamed scene should still be found as a version of the new element.
+		rel, err := filepath.Rel(sceneDir, scene)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		idxs := reName.FindStringSubmatchIndex(rel)
+		if idxs == nil {
+			return nil, fmt.Errorf("invalid element name %q: %s doesn't match the scene name query", newElem, rel)
+		}
+		gotElem := string(reName.ExpandString([]byte{}, "$ELEM", rel, idxs))
+		gotVer := string(reName.ExpandString([]byte{}, "$VER", rel, idxs))
+		extra := string(reName.ExpandString([]byte{}, "$EXTRA", rel, idxs))
+		if gotElem != newElem || gotVer != v.Name || extra != "" {
+			return nil, fmt.Errorf("invalid element name %q: %s will be found as %s / %s", newElem, rel, gotElem, gotVer)
+		}
+		_, err = os.Stat(scene)
+		if err == nil {
+			return nil, fmt.Errorf("scene exists: %s", scene)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		moves = append(moves, sceneMove{from: v.Scene, to: scene})
+	}
+	return moves, nil
+}
+
+// moveScenes moves scenes in order, and moves back the moved ones when it fails in the middle.
+func moveScenes(moves []sceneMove) error {
+	for i, m := range moves {
+		err := moveScene(m.from, m.to)
+		if err != nil {
+			err = fmt.Errorf("rename %s: %v", m.from, err)
+			last := i - 1
+			if _, serr := os.Stat(m.from); errors.Is(serr, os.ErrNotExist) {
+				// the scene is moved, but some of it's sidecars aren't.
+				last = i
+			}
+			for j := last; j >= 0; j-- {
+				rerr := moveScene(moves[j].to, moves[j].from)
+				if rerr != nil {
+					return fmt.Errorf("%v; and couldn't move back %s: %v", err, moves[j].to, rerr)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchiveVersion moves a version into the archive directory of the scene directory.
+// It refuses to archive a version opened by someone.
+func (a *App) ArchiveVersion(path, elem, ver, prog string) error {
+	v, sceneDir, err := a.findVersion(path, elem, ver, prog)
+	if err != nil {
+		return err
+	}
+	err = checkNotLocked([]Version{v})
+	if err != nil {
+		return err
+	}
+	return archiveScene(v.Scene, sceneDir)
+}
+
+// ArchiveOldVersions moves versions of an element except the latest ones into the archive directory.
+// It returns the number of archived versions. Nothing is archived when any of them is opened by someone.
+func (a *App) ArchiveOldVersions(path, elem, prog string, keep int) (int, error) {
+	if keep < 1 {
+		return 0, fmt.Errorf("should keep at least one version")
+	}
+	e, sceneDir, err := a.findElem(path, elem, prog)
+	if err != nil {
+		return 0, err
+	}
+	vers := make([]Version, len(e.Versions))
+	copy(vers, e.Versions)
+	sortVersions(vers, false)
+	if len(vers) <= keep {
+		return 0, nil
+	}
+	err = checkNotLocked(vers[keep:])
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := keep; i < len(vers); i++ {
+		err := archiveScene(vers[i].Scene, sceneDir)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// archiveScene moves a scene into the archive directory, keeping it's relative path to the scene directory.
+func archiveScene(scene, sceneDir string) error {
+	rel, err := filepath.Rel(sceneDir, scene)
+	if err != nil {
+		return err
+	}
+	return moveScene(scene, filepath.Join(sceneDir, archiveDirName, rel))
+}
+
+// DeleteVersion deletes a version after the user confirmed it.
+// It refuses to delete a version opened by someone.
+// It returns whether the version was deleted.
+func (a *App) DeleteVersion(path, elem, ver, prog string) (bool, error) {
+	v, _, err := a.findVersion(path, elem, ver, prog)
+	if err != nil {
+		return false, err
+	}
+	err = checkNotLocked([]Version{v})
+	if err != nil {
+		return false, err
+	}
+	ok, err := a.confirm("Delete Version", "Delete "+v.Scene+"?\nIt cannot be undone.")
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	err = removeScene(v.Scene)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteElement deletes all versions of an element after the user confirmed it.
+// It refuses to delete when any of the versions is opened by someone.
+// It returns whether the element was deleted.
+func (a *App) DeleteElement(path, elem, prog string) (bool, error) {
+	e, _, err := a.findElem(path, elem, prog)
+	if err != nil {
+		return false, err
+	}
+	err = checkNotLocked(e.Versions)
+	if err != nil {
+		return false, err
+	}
+	msg := fmt.Sprintf("Delete %d versions of %s (%s)?\nIt cannot be undone.", len(e.Versions), elem, prog)
+	ok, err := a.confirm("Delete Element", msg)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	for _, v := range e.Versions {
+		err := removeScene(v.Scene)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// writeSceneWithSidecars writes a scene and all of it's sidecar files.
+func writeSceneWithSidecars(t *testing.T, scene string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(canalFile(scene, "")), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(scene, []byte("scene"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range sceneSidecarExts {
+		err := os.WriteFile(canalFile(scene, ext), []byte("{}"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMoveSceneSidecars(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a_v001.blend")
+	dst := filepath.Join(dir, archiveDirName, "a_v001.blend")
+	writeSceneWithSidecars(t, src)
+	err := moveScene(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range append([]string{""}, sceneSidecarExts...) {
+		from, to := canalFile(src, ext), canalFile(dst, ext)
+		if ext == "" {
+			from, to = src, dst
+		}
+		if _, err := os.Stat(from); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s is left: %v", from, err)
+		}
+		if _, err := os.Stat(to); err != nil {
+			t.Errorf("%s is not moved: %v", to, err)
+		}
+	}
+}
+
+func TestRemoveSceneSidecars(t *testing.T) {
+	scene := filepath.Join(t.TempDir(), "a_v001.blend")
+	writeSceneWithSidecars(t, scene)
+	err := removeScene(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ents, err := os.ReadDir(filepath.Dir(canalFile(scene, "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 0 {
+		t.Fatalf("sidecars are left: %v", ents)
+	}
+}
+
+func TestCheckNotLocked(t *testing.T) {
+	dir := t.TempDir()
+	free := Version{Name: "v001", Scene: filepath.Join(dir, "a_v001.blend")}
+	locked := Version{Name: "v002", Scene: filepath.Join(dir, "a_v002.blend")}
+	stale := Version{Name: "v003", Scene: filepath.Join(dir, "a_v003.blend")}
+	host, _ := os.Hostname()
+	writeLock := func(scene string, pid int) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Dir(sceneLockFile(scene)), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(&SceneLock{User: "other", Host: host, PID: pid, At: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(sceneLockFile(scene), data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLock(locked.Scene, os.Getpid())
+	writeLock(stale.Scene, deadPID(t))
+	err := checkNotLocked([]Version{free, stale})
+	if err != nil {
+		t.Fatalf("versions without a live lock: %v", err)
+	}
+	err = checkNotLocked([]Version{free, locked, stale})
+	if err == nil {
+		t.Fatal("a version opened by someone should be refused")
+	}
+}
+
+func TestPlanElementRename(t *testing.T) {
+	dir := t.TempDir()
+	reName := regexp.MustCompile(`^(?P<ELEM>[a-z]+)_(?P<VER>v\d+)\.blend$`)
+	vers := []Version{
+		{Name: "v001", Scene: filepath.Join(dir, "fx_v001.blend")},
+		{Name: "v002", Scene: filepath.Join(dir, "fx_v002.blend")},
+	}
+	sceneFile := func(elem string) func(ver string) (string, error) {
+		return func(ver string) (string, error) {
+			return dir + "/" + elem + "_" + ver + ".blend", nil
+		}
+	}
+	err := os.WriteFile(filepath.Join(dir, "taken_v002.blend"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		elem string
+		ok   bool
+	}{
+		{elem: "smoke", ok: true},
+		// doesn't match the query.
+		{elem: "smoke2", ok: false},
+		{elem: "Smoke", ok: false},
+		// would be found as another element.
+		{elem: "smoke_v003", ok: false},
+		// out of the scene directory.
+		{elem: "../smoke", ok: false},
+		// v002 exists already.
+		{elem: "taken", ok: false},
+	}
+	for _, c := range cases {
+		moves, err := planElementRename(vers, c.elem, dir, reName, sceneFile(c.elem))
+		if !c.ok {
+			if err == nil {
+				t.Errorf("%q: want error, got %v", c.elem, moves)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.elem, err)
+			continue
+		}
+		if len(moves) != len(vers) {
+			t.Errorf("%q: got %d moves, want %d", c.elem, len(moves), len(vers))
+		}
+	}
+}
+
+func TestMoveScenesRollback(t *testing.T) {
+	dir := t.TempDir()
+	moves := []sceneMove{
+		{from: filepath.Join(dir, "fx_v001.blend"), to: filepath.Join(dir, "smoke_v001.blend")},
+		{from: filepath.Join(dir, "fx_v002.blend"), to: filepath.Join(dir, "smoke_v002.blend")},
+		{from: filepath.Join(dir, "fx_v003.blend"), to: filepath.Join(dir, "smoke_v003.blend")},
+	}
+	for _, m := range moves {
+		writeSceneWithSidecars(t, m.from)
+	}
+	// someone created the scene after the renames were planned.
+	err := os.WriteFile(moves[2].to, []byte("other"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = moveScenes(moves)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	for _, m := range moves {
+		for _, ext := range append([]string{""}, sceneSidecarExts...) {
+			from := canalFile(m.from, ext)
+			if ext == "" {
+				from = m.from
+			}
+			if _, err := os.Stat(from); err != nil {
+				t.Errorf("%s is not moved back: %v", from, err)
+			}
+		}
+	}
+	for _, m := range moves[:2] {
+		if _, err := os.Stat(m.to); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s is left: %v", m.to, err)
+		}
+	}
+	data, err := os.ReadFile(moves[2].to)
+	if err != nil || string(data) != "other" {
+		t.Fatalf("other's scene is changed: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SceneMeta is information about a scene file, that couldn't be stored in the scene itself.
+// It is saved as a json file in the '.canal' directory next to the scene.
+type SceneMeta struct {
+	// CreatedBy is the user who created the scene through the app.
+	CreatedBy string
+	CreatedAt time.Time
+	// From is the scene path where the scene is copied from, if any.
+	From string
+	// Note is a note about the scene, which is usually what has changed from the previous version.
+	Note string
+	// Checksum is a cached checksum of the scene,
+	// which is valid while the scene has ChecksumSize and ChecksumModTime.
+	Checksum        string
+	ChecksumSize    int64
+	ChecksumModTime time.Time
+	// PublishedBy is the user who published the scene, if it is published.
+	PublishedBy string
+	PublishedAt time.Time
+	// PublishedFile is the file path recorded to forge when published.
+	// It is different from the scene when the scene was copied to a publish directory.
+	PublishedFile  string
+	PublishComment string
+	// PublishedChecksum is the checksum of the scene when it was published.
+	PublishedChecksum string
+}
+
+// canalFile returns path of a file in the '.canal' directory,
+// that holds information about a scene.
+func canalFile(scene, ext string) string {
+	return filepath.Join(filepath.Dir(scene), ".canal", filepath.Base(scene)+ext)
+}
+
+// sceneSidecarExts are extensions of files in the '.canal' directory those belong to a scene.
+// They should follow the scene when it is moved or removed.
+var sceneSidecarExts = []string{".json", ".lock", ".reserved"}
+
+// sceneMetaFile returns path of the meta file for a scene.
+func sceneMetaFile(scene string) string {
+	return canalFile(scene, ".json")
+}
+
+// readSceneMeta reads meta of a scene.
+// It returns nil without an error when the scene doesn't have meta.
+func readSceneMeta(scene string) (*SceneMeta, error) {
+	data, err := os.ReadFile(sceneMetaFile(scene))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	meta := &SceneMeta{}
+	err = json.Unmarshal(data, meta)
+	if err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// writeSceneMeta writes meta of a scene.
+// It writes to a temporary file then renames it, so readers never see a partially written meta.
+func writeSceneMeta(scene string, meta *SceneMeta) error {
+	f := sceneMetaFile(scene)
+	err := os.MkdirAll(filepath.Dir(f), 0755)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f, data)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory, then renames it to f.
+func writeFileAtomic(f string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f), filepath.Base(f)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// CreateTemp makes the file only readable by the user.
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	err = os.Rename(tmp.Name(), f)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// reserveExpire is how long a reservation from other host is valid.
+// A reservation is refreshed while it is held, so it only expires when the holder has gone.
+const reserveExpire = 10 * time.Minute
+
+// reserveRefreshInterval is how often a held reservation is refreshed.
+// It is a variable for tests.
+var reserveRefreshInterval = reserveExpire / 4
+
+// sceneReserveFile returns path of the reservation file for a scene.
+func sceneReserveFile(scene string) string {
+	return canalFile(scene, ".reserved")
+}
+
+// readSceneReservation reads reservation of a scene.
+// It has the same form with a scene lock, so it could be checked whether it is stale.
+// It returns nil without an error when the scene isn't reserved.
+func readSceneReservation(scene string) (*SceneLock, error) {
+	data, err := os.ReadFile(sceneReserveFile(scene))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	r := &SceneLock{}
+	err = json.Unmarshal(data, r)
+	if err != nil {
+		// reservation from an older version of the app, or a broken one.
+		return nil, err
+	}
+	return r, nil
+}
+
+// sceneReservation is a reservation of a scene path held by this process.
+// It is refreshed in background until released,
+// so it doesn't expire while a program is creating the scene, however long it takes.
+type sceneReservation struct {
+	scene string
+	owner SceneLock
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// reserveScene reserves a scene path for a new version atomically,
+// so other users (or clicks) couldn't take the same version until it is released.
+// A reservation left by a crashed process is stale, and will be taken over.
+// It returns an error that wraps os.ErrExist, if the scene or it's reservation already exists.
+// The caller should Release the reservation after the scene is created.
+func reserveScene(scene, user string) (*sceneReservation, error) {
+	_, err := os.Stat(scene)
+	if err == nil {
+		return nil, fmt.Errorf("scene exists: %w", os.ErrExist)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	res := &sceneReservation{
+		scene: scene,
+		owner: SceneLock{User: user, Host: host, PID: os.Getpid(), At: time.Now()},
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	rf := sceneReserveFile(scene)
+	err = os.MkdirAll(filepath.Dir(rf), 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = createReservation(rf, &res.owner)
+	if errors.Is(err, os.ErrExist) {
+		r, rerr := readSceneReservation(scene)
+		if rerr != nil || r == nil || !r.staleAfter(reserveExpire) {
+			return nil, err
+		}
+		// there is a small window that another process also takes over the stale reservation,
+		// and removes ours. It is acceptable as it only happens after a crash.
+		rerr = os.Remove(rf)
+		if rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			return nil, rerr
+		}
+		err = createReservation(rf, &res.owner)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the scene could be created by someone who didn't reserve it, in the mean time.
+	_, err = os.Stat(scene)
+	if err == nil {
+		os.Remove(rf)
+		return nil, fmt.Errorf("scene exists: %w", os.ErrExist)
+	}
+	go res.keep()
+	return res, nil
+}
+
+// createReservation creates a reservation file for the owner exclusively.
+func createReservation(rf string, owner *SceneLock) error {
+	data, err := json.MarshalIndent(owner, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(rf, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		os.Remove(rf)
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		os.Remove(rf)
+		return err
+	}
+	return nil
+}
+
+// owns returns true if r is a reservation made by res.
+func (res *sceneReservation) owns(r *SceneLock) bool {
+	return r != nil && r.Host == res.owner.Host && r.User == res.owner.User && r.PID == res.owner.PID
+}
+
+// keep refreshes the reservation periodically until it is released,
+// or it is taken by someone else.
+func (res *sceneReservation) keep() {
+	defer close(res.done)
+	t := time.NewTicker(reserveRefreshInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-res.stop:
+			return
+		case <-t.C:
+			ok, err := res.refresh()
+			if err != nil {
+				log.Printf("refresh reservation of %s: %v", res.scene, err)
+				continue
+			}
+			if !ok {
+				log.Printf("reservation of %s is taken by someone else", res.scene)
+				return
+			}
+		}
+	}
+}
+
+// refresh updates time of the reservation, if it is still ours.
+// It returns false if the reservation is gone or taken over.
+func (res *sceneReservation) refresh() (bool, error) {
+	r, err := readSceneReservation(res.scene)
+	if err != nil {
+		return false, err
+	}
+	if !res.owns(r) {
+		return false, nil
+	}
+	res.owner.At = time.Now()
+	data, err := json.MarshalIndent(&res.owner, "", "\t")
+	if err != nil {
+		return false, err
+	}
+	err = writeFileAtomic(sceneReserveFile(res.scene), data)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Release stops refreshing the reservation and removes it,
+// only when it is still ours. A reservation taken over by others is left as is.
+// It is safe to call Release more than once.
+func (res *sceneReservation) Release() error {
+	var err error
+	res.once.Do(func() {
+		close(res.stop)
+		<-res.done
+		var r *SceneLock
+		r, err = readSceneReservation(res.scene)
+		if err != nil || !res.owns(r) {
+			return
+		}
+		err = os.Remove(sceneReserveFile(res.scene))
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+	})
+	return err
+}
+
+// updateSceneMeta updates meta of a scene with fn.
+// fn will get an empty meta if the scene doesn't have meta yet.
+func updateSceneMeta(scene string, fn func(meta *SceneMeta)) error {
+	meta, err := readSceneMeta(scene)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		meta = &SceneMeta{}
+	}
+	fn(meta)
+	return writeSceneMeta(scene, meta)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReserveNextSceneConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	scheme, err := parseVerScheme("v001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 20
+	res := make([]*sceneReservation, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := []string{"SHOT=sh010", "ELEM=fx", "EXT=blend"}
+			res[i], _, errs[i] = reserveNextScene(dir, "${SHOT}_${ELEM}_${VER}.${EXT}", scheme, scheme.Start, env, "user")
+		}(i)
+	}
+	wg.Wait()
+	seen := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("reserve %d: %v", i, errs[i])
+		}
+		defer res[i].Release()
+		if seen[res[i].scene] {
+			t.Fatalf("scene reserved more than once: %s", res[i].scene)
+		}
+		seen[res[i].scene] = true
+	}
+	for i := 1; i <= n; i++ {
+		scene := dir + "/sh010_fx_" + scheme.Format(i) + ".blend"
+		if !seen[scene] {
+			t.Fatalf("version not reserved: %s", scene)
+		}
+	}
+}
+
+func TestReserveSceneConcurrent(t *testing.T) {
+	scene := filepath.Join(t.TempDir(), "a_v001.blend")
+	const n = 20
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		won  []*sceneReservation
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := reserveScene(scene, "user")
+			if err != nil {
+				if !errors.Is(err, os.ErrExist) {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			lock.Lock()
+			won = append(won, res)
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	for _, res := range won {
+		res.Release()
+	}
+	if len(won) != 1 {
+		t.Fatalf("got %d reservations, want 1", len(won))
+	}
+}
+
+func TestReserveSceneExisting(t *testing.T) {
+	scene := filepath.Join(t.TempDir(), "a_v001.blend")
+	err := os.WriteFile(scene, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = reserveScene(scene, "user")
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatalf("got %v, want os.ErrExist", err)
+	}
+}
+
+func TestReserveSceneStale(t *testing.T) {
+	scene := filepath.Join(t.TempDir(), "a_v001.blend")
+	writeReservation := func(r *SceneLock) {
+		t.Helper()
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rf := sceneReserveFile(scene)
+		err = os.MkdirAll(filepath.Dir(rf), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(rf, data, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	host, _ := os.Hostname()
+
+	// a live reservation from other host is respected.
+	writeReservation(&SceneLock{User: "other", Host: "other-host", PID: 1, At: time.Now()})
+	_, err := reserveScene(scene, "user")
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatalf("got %v, want os.ErrExist", err)
+	}
+
+	// a reservation from other host expires.
+	writeReservation(&SceneLock{User: "other", Host: "other-host", PID: 1, At: time.Now().Add(-2 * reserveExpire)})
+	res, err := reserveScene(scene, "user")
+	if err != nil {
+		t.Fatalf("stale reservation from other host not taken over: %v", err)
+	}
+	r, err := readSceneReservation(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.User != "user" || r.PID != os.Getpid() {
+		t.Fatalf("unexpected reservation: %+v", r)
+	}
+	res.Release()
+
+	// a reservation of a process that has gone is stale immediately.
+	// pid of a finished child process is not alive.
+	writeReservation(&SceneLock{User: "other", Host: host, PID: deadPID(t), At: time.Now()})
+	res, err = reserveScene(scene, "user")
+	if err != nil {
+		t.Fatalf("stale reservation of dead process not taken over: %v", err)
+	}
+	res.Release()
+}
+
+func TestReleaseSceneTakenOver(t *testing.T) {
+	scene := filepath.Join(t.TempDir(), "a_v001.blend")
+	res, err := reserveScene(scene, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// other host took over the reservation, after it was considered stale.
+	other := &SceneLock{User: "other", Host: "other-host", PID: res.owner.PID, At: time.Now()}
+	data, err := json.Marshal(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(sceneReserveFile(scene), data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = res.Release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := readSceneReservation(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || r.Host != "other-host" {
+		t.Fatalf("reservation of other host is removed: %+v", r)
+	}
+}
+
+func TestReserveSceneRefresh(t *testing.T) {
+	interval := reserveRefreshInterval
+	reserveRefreshInterval = 10 * time.Millisecond
+	defer func() { reserveRefreshInterval = interval }()
+
+	scene := filepath.Join(t.TempDir(), "a_v001.blend")
+	res, err := reserveScene(scene, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := readSceneReservation(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r, err := readSceneReservation(scene)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.At.After(first.At) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reservation is not refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	err = res.Release()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := readSceneReservation(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != nil {
+		t.Fatalf("reservation is not released: %+v", r)
+	}
+}
+
+// deadPID returns pid of a process that has finished.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	err := cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd.ProcessState.Pid()
+}
+
+func TestWriteSceneMeta(t *testing.T) {
+	scene := filepath.Join(t.TempDir(), "a_v001.blend")
+	err := writeSceneMeta(scene, &SceneMeta{Note: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = updateSceneMeta(scene, func(meta *SceneMeta) {
+		meta.Note = "second"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := readSceneMeta(scene)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Note != "second" {
+		t.Fatalf("got note %q, want %q", meta.Note, "second")
+	}
+	ents, err := os.ReadDir(filepath.Dir(sceneMetaFile(scene)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 1 {
+		t.Fatalf("temporary files are left: %v", ents)
+	}
+}
+
+func TestStatVersionBrokenMeta(t *testing.T) {
+	scene := filepath.Join(t.TempDir(), "a_v001.blend")
+	err := os.WriteFile(scene, []byte("scene"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := sceneMetaFile(scene)
+	err = os.MkdirAll(filepath.Dir(f), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(f, []byte("{broken"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &Version{Name: "v001", Scene: scene}
+	err = statVersion(v)
+	if err != nil {
+		t.Fatalf("broken meta should be ignored: %v", err)
+	}
+	if v.Size != 5 || v.Note != "" {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultNoteProperty is the entry property where notes were kept by older versions of the app,
+// when NOTE_PROPERTY environ is not defined. Notes in it are still shown, but new notes aren't written to it.
+const defaultNoteProperty = "note"
+
+// noteEnvironPrefix is the prefix of record environs those keep notes.
+const noteEnvironPrefix = "CANAL_NOTE_"
+
+// Note is a note or comment left on an entry.
+type Note struct {
+	ID   int
+	User string
+	When time.Time
+	// ReplyTo is ID of the note this note replies to.
+	// It is 0 when the note starts a new thread.
+	ReplyTo int
+	// Elem, Ver and Program refer a version the note is about, if any.
+	Elem    string
+	Ver     string
+	Program string
+	Text    string
+}
+
+// EntryNotes are notes of an entry.
+type EntryNotes struct {
+	Path  string
+	Notes []*Note
+}
+
+// noteHeader matches the first line of a note.
+// ex) #2 2022-09-04T13:25:50Z john re:1 ref:maya/fx/v003
+var noteHeader = regexp.MustCompile(`^#(\d+) (\S+) (\S+)((?: \w+:\S*)*)$`)
+
+// parseNotes parses notes from a note property value.
+// Lines before the first note header will be ignored.
+func parseNotes(value string) []*Note {
+	notes := make([]*Note, 0)
+	var n *Note
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	for _, l := range lines {
+		m := noteHeader.FindStringSubmatch(l)
+		if m == nil {
+			if n != nil {
+				n.Text += unescapeNoteLine(l) + "\n"
+			}
+			continue
+		}
+		id, _ := strconv.Atoi(m[1])
+		when, err := time.Parse(time.RFC3339, m[2])
+		if err != nil {
+			if n != nil {
+				n.Text += l + "\n"
+			}
+			continue
+		}
+		n = &Note{ID: id, When: when, User: m[3]}
+		for _, opt := range strings.Fields(m[4]) {
+			k, v, _ := strings.Cut(opt, ":")
+			switch k {
+			case "re":
+				n.ReplyTo, _ = strconv.Atoi(v)
+			case "ref":
+				toks := strings.SplitN(v, "/", 3)
+				if len(toks) == 3 {
+					n.Program, n.Elem, n.Ver = toks[0], toks[1], toks[2]
+				}
+			}
+		}
+		notes = append(notes, n)
+	}
+	for _, n := range notes {
+		n.Text = strings.TrimSpace(n.Text)
+	}
+	return notes
+}
+
+// formatNote formats a note, so it could be appended to a note property value.
+func formatNote(n *Note) string {
+	s := fmt.Sprintf("#%d %s %s", n.ID, n.When.UTC().Format(time.RFC3339), n.User)
+	if n.ReplyTo != 0 {
+		s += fmt.Sprintf(" re:%d", n.ReplyTo)
+	}
+	if n.Ver != "" {
+		s += fmt.Sprintf(" ref:%s/%s/%s", n.Program, n.Elem, n.Ver)
+	}
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(n.Text, "\r\n", "\n")), "\n")
+	for i, l := range lines {
+		lines[i] = escapeNoteLine(l)
+	}
+	return s + "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// escapeNoteLine escapes a line of note text, so it couldn't be taken as a note header.
+// Lines starting with '#' or '\' are prefixed with '\'.
+func escapeNoteLine(l string) string {
+	if strings.HasPrefix(l, "#") || strings.HasPrefix(l, `\`) {
+		return `\` + l
+	}
+	return l
+}
+
+// unescapeNoteLine reverts escapeNoteLine.
+func unescapeNoteLine(l string) string {
+	if strings.HasPrefix(l, `\#`) || strings.HasPrefix(l, `\\`) {
+		return l[1:]
+	}
+	return l
+}
+
+// noteProperty returns the note property name of the entry.
+func (a *App) noteProperty(path string) (string, error) {
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return "", err
+	}
+	prop := getEnv("NOTE_PROPERTY", env)
+	if prop == "" {
+		prop = defaultNoteProperty
+	}
+	return prop, nil
+}
+
+// entryNoteEnvirons returns notes kept in environs of the entry and its parents, by entry id.
+func (a *App) entryNoteEnvirons(path string) (map[int][]*Note, error) {
+	forgeEnv, err := entryEnvirons(a.host, a.session, path)
+	if err != nil {
+		return nil, err
+	}
+	notes := make(map[int][]*Note)
+	for _, e := range forgeEnv {
+		entID, id, ok := parseRecordEnvironName(noteEnvironPrefix, e.Name)
+		if !ok {
+			continue
+		}
+		for _, n := range parseNotes(e.Value) {
+			// the environ name is the source of truth.
+			n.ID = id
+			notes[entID] = append(notes[entID], n)
+		}
+	}
+	return notes, nil
+}
+
+// EntryNotes returns notes of the entry and its parents, from root to the entry.
+// Entries without notes are omitted.
+func (a *App) EntryNotes(path string) ([]*EntryNotes, error) {
+	prop, err := a.noteProperty(path)
+	if err != nil {
+		return nil, err
+	}
+	ents, err := a.ParentEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	ents = append(ents, ent)
+	envNotes, err := a.entryNoteEnvirons(path)
+	if err != nil {
+		return nil, err
+	}
+	entNotes := make([]*EntryNotes, 0)
+	for _, e := range ents {
+		notes := make([]*Note, 0)
+		if p := e.Property[prop]; p != nil {
+			notes = append(notes, parseNotes(p.Value)...)
+		}
+		notes = append(notes, envNotes[e.ID]...)
+		if len(notes) == 0 {
+			continue
+		}
+		sort.SliceStable(notes, func(i, j int) bool {
+			return notes[i].ID < notes[j].ID
+		})
+		entNotes = append(entNotes, &EntryNotes{Path: e.Path, Notes: notes})
+	}
+	return entNotes, nil
+}
+
+// PostNote leaves a note on the entry.
+// It could reply to another note of the entry with replyTo, and refer a version with elem, ver and prog.
+// Pass 0 as replyTo and empty ver when they are not needed.
+func (a *App) PostNote(path, text string, replyTo int, elem, ver, prog string) error {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return fmt.Errorf("empty note")
+	}
+	if ver != "" {
+		if strings.ContainsAny(prog+elem+ver, " /") {
+			return fmt.Errorf("invalid version reference: %s/%s/%s", prog, elem, ver)
+		}
+	}
+	entNotes, err := a.EntryNotes(path)
+	if err != nil {
+		return err
+	}
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return err
+	}
+	id := 1
+	found := replyTo == 0
+	for _, en := range entNotes {
+		if en.Path != path {
+			continue
+		}
+		for _, n := range en.Notes {
+			if n.ID >= id {
+				id = n.ID + 1
+			}
+			if n.ID == replyTo {
+				found = true
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("note not exists: #%d", replyTo)
+	}
+	n := &Note{
+		User:    a.user,
+		When:    time.Now().UTC().Truncate(time.Second),
+		ReplyTo: replyTo,
+		Elem:    elem,
+		Ver:     ver,
+		Program: prog,
+		Text:    text,
+	}
+	_, err = a.addRecordEnviron(noteEnvironPrefix, path, ent.ID, id, func(id int) (string, error) {
+		n.ID = id
+		return formatNote(n), nil
+	})
+	if err != nil {
+		return fmt.Errorf("post note: %v", err)
+	}
+	return a.refreshEntries(path)
+}
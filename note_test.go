@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoteRoundTrip(t *testing.T) {
+	when := time.Date(2022, 9, 4, 13, 25, 50, 0, time.UTC)
+	notes := []*Note{
+		{ID: 1, User: "john", When: when, Text: "first note"},
+		{ID: 2, User: "boss", When: when, ReplyTo: 1, Program: "blender", Elem: "fx", Ver: "v003", Text: "looks good"},
+		{ID: 3, User: "john", When: when, Text: "#3 2022-01-01T00:00:00Z boss\nnot a header"},
+		{ID: 4, User: "john", When: when, Text: `\#escaped already` + "\n" + `\\two` + "\n# heading"},
+	}
+	value := ""
+	for _, n := range notes {
+		if value != "" {
+			value += "\n"
+		}
+		value += formatNote(n)
+	}
+	got := parseNotes(value)
+	if len(got) != len(notes) {
+		t.Fatalf("got %d notes, want %d:\n%s", len(got), len(notes), value)
+	}
+	for i, n := range notes {
+		g := got[i]
+		if g.ID != n.ID || g.User != n.User || !g.When.Equal(n.When) || g.ReplyTo != n.ReplyTo {
+			t.Errorf("note %d: got %+v, want %+v", i, g, n)
+		}
+		if g.Program != n.Program || g.Elem != n.Elem || g.Ver != n.Ver {
+			t.Errorf("note %d: got ref %s/%s/%s, want %s/%s/%s", i, g.Program, g.Elem, g.Ver, n.Program, n.Elem, n.Ver)
+		}
+		if g.Text != n.Text {
+			t.Errorf("note %d: got text %q, want %q", i, g.Text, n.Text)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output is an output of a part, like renders, caches and playblasts.
+// Output in the app represents a bunch of files in an output directory which can be grouped by a naming rule.
+type Output struct {
+	Name     string
+	Versions []*OutputVersion
+}
+
+// OutputVersion is a version of an output, that holds it's sequences.
+type OutputVersion struct {
+	Name      string
+	Num       int
+	Sequences []*Sequence
+}
+
+// Sequence is a group of files those have same name except their frame numbers.
+// A file without a frame number is also a Sequence that has only one file.
+type Sequence struct {
+	// Path is the path of the sequence, that has '#'s in place of the frame numbers.
+	// It has a single '#' when the frame numbers aren't padded to the same width.
+	// (ex: /show/out/beauty.####.exr, /show/out/beauty.#.exr)
+	Path string
+	// Frames is the number of frame files. It is 0 when it isn't a frame sequence.
+	Frames int
+	// Padding is the number of digits for frame numbers, when all frames have the same width.
+	// It is 0 otherwise.
+	Padding int
+	Start   int
+	End     int
+	// Missing is ranges of frames those don't exist between Start and End.
+	Missing []FrameRange
+	// MissingFrames is the number of frames in Missing.
+	MissingFrames int
+	// First is the file path of the first frame.
+	First   string
+	Size    int64
+	ModTime time.Time
+	// CanThumbnail indicates the first frame could be used as a thumbnail.
+	CanThumbnail bool
+}
+
+// FrameRange is a range of frames, End inclusive.
+type FrameRange struct {
+	Start int
+	End   int
+}
+
+// reFrame matches a file name which has frame number right before the extension.
+// (ex: beauty.1001.exr, beauty_1001.exr)
+var reFrame = regexp.MustCompile(`^(.*?[._])(\d+)(\.[^.]+)$`)
+
+// ListOutputs returns outputs of a part entry each of which holds versions and sequences as well.
+// Outputs are found in OUTPUT_DIR by OUTPUT_NAME_QUERY environ, which can have ELEM and VER groups.
+// It returns an empty list when OUTPUT_DIR environ is not defined.
+func (a *App) ListOutputs(path string) ([]*Output, error) {
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return nil, err
+	}
+	outputDir := getEnv("OUTPUT_DIR", env)
+	if outputDir == "" {
+		return []*Output{}, nil
+	}
+	outputDir = evalEnvString(outputDir, env)
+	outputName := getEnv("OUTPUT_NAME_QUERY", env)
+	if outputName == "" {
+		return nil, fmt.Errorf("no output name information: check OUTPUT_NAME_QUERY environ")
+	}
+	outputName = evalEnvString(outputName, env)
+	reName, err := regexp.Compile("^" + outputName + "$") // match as a whole
+	if err != nil {
+		return nil, err
+	}
+	// there isn't a name template for outputs, the query shouldn't have '/' other than separators.
+	files, err := listSceneFiles(outputDir, pathDepth(outputName))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return []*Output{}, nil
+	}
+	scheme, err := verSchemeOf(env)
+	if err != nil {
+		return nil, err
+	}
+	output := make(map[string]*Output)
+	version := make(map[string]*OutputVersion)
+	seqFiles := make(map[string][]string)
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		name := f.Name
+		idxs := reName.FindStringSubmatchIndex(name)
+		if idxs == nil {
+			continue
+		}
+		el := string(reName.ExpandString([]byte{}, "$ELEM", name, idxs))
+		ver := string(reName.ExpandString([]byte{}, "$VER", name, idxs))
+		o := output[el]
+		if o == nil {
+			o = &Output{Name: el}
+			output[el] = o
+		}
+		v := version[el+"/"+ver]
+		if v == nil {
+			v = &OutputVersion{Name: ver}
+			v.Num, _ = scheme.Parse(ver)
+			o.Versions = append(o.Versions, v)
+			version[el+"/"+ver] = v
+		}
+		seqFiles[el+"/"+ver] = append(seqFiles[el+"/"+ver], outputDir+"/"+name)
+	}
+	for key, v := range version {
+		v.Sequences = groupSequences(seqFiles[key])
+		for _, s := range v.Sequences {
+			s.CanThumbnail = a.canThumbnail(s.First)
+		}
+	}
+	outputs := make([]*Output, 0, len(output))
+	for _, o := range output {
+		sort.Slice(o.Versions, func(i, j int) bool {
+			cmp := o.Versions[i].Num - o.Versions[j].Num
+			if cmp != 0 {
+				return cmp > 0
+			}
+			return o.Versions[i].Name > o.Versions[j].Name
+		})
+		outputs = append(outputs, o)
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].Name < outputs[j].Name
+	})
+	return outputs, nil
+}
+
+// groupSequences groups files into sequences by their frame numbers.
+// Files having the same name except their frame numbers are a sequence,
+// even if their frame numbers have different widths. (ex: beauty.999.exr, beauty.1000.exr)
+func groupSequences(files []string) []*Sequence {
+	seq := make(map[string]*Sequence)
+	frames := make(map[string][]int)
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			// removed while listing
+			continue
+		}
+		key := f
+		width := 0
+		frame := 0
+		dir, base := filepath.Split(f)
+		m := reFrame.FindStringSubmatch(base)
+		if m != nil {
+			frame, err = strconv.Atoi(m[2])
+			if err == nil {
+				width = len(m[2])
+				// the key cannot be a file path, as it has a NUL character.
+				key = dir + m[1] + "\x00" + m[3]
+			}
+		}
+		s := seq[key]
+		if s == nil {
+			s = &Sequence{Path: f, Padding: width, Start: frame, End: frame, First: f}
+			if width != 0 {
+				s.Path = dir + m[1] + strings.Repeat("#", width) + m[3]
+			}
+			seq[key] = s
+		}
+		if width != 0 {
+			s.Frames++
+			if width != s.Padding && s.Padding != 0 {
+				s.Padding = 0
+				s.Path = dir + m[1] + "#" + m[3]
+			}
+			frames[key] = append(frames[key], frame)
+		}
+		if frame < s.Start {
+			s.Start = frame
+			s.First = f
+		}
+		if frame > s.End {
+			s.End = frame
+		}
+		s.Size += fi.Size()
+		if fi.ModTime().After(s.ModTime) {
+			s.ModTime = fi.ModTime()
+		}
+	}
+	seqs := make([]*Sequence, 0, len(seq))
+	for key, s := range seq {
+		s.Missing, s.MissingFrames = missingFrames(frames[key])
+		seqs = append(seqs, s)
+	}
+	sort.Slice(seqs, func(i, j int) bool {
+		return seqs[i].Path < seqs[j].Path
+	})
+	return seqs
+}
+
+// missingFrames returns ranges of frames those are not in frames, between the first and last frame.
+// It also returns the number of the missing frames.
+// It doesn't allocate for each missing frame, as a stray frame could make a huge gap.
+func missingFrames(frames []int) ([]FrameRange, int) {
+	missing := make([]FrameRange, 0)
+	n := 0
+	sort.Ints(frames)
+	for i := 1; i < len(frames); i++ {
+		prev, f := frames[i-1], frames[i]
+		if f-prev <= 1 {
+			continue
+		}
+		missing = append(missing, FrameRange{Start: prev + 1, End: f - 1})
+		n += f - prev - 1
+	}
+	return missing, n
+}
+
+// OpenSequence opens a sequence of an entry with the viewer program.
+// The viewer's OpenCmd can use SEQ, SEQ_FIRST, SEQ_START and SEQ_END environs.
+func (a *App) OpenSequence(path, seq string) error {
+	pg := a.config.Viewer
+	if pg == nil {
+		return fmt.Errorf("viewer not specified")
+	}
+	s, env, err := a.findSequence(path, seq)
+	if err != nil {
+		return err
+	}
+	env = append(env, "SEQ="+s.Path)
+	env = append(env, "SEQ_FIRST="+s.First)
+	env = append(env, "SEQ_START="+strconv.Itoa(s.Start))
+	env = append(env, "SEQ_END="+strconv.Itoa(s.End))
+	env = append(env, "FORGE_SESSION="+a.session)
+	openCmd, err := a.programCmd(pg, pg.OpenCmd, env)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(openCmd[0], openCmd[1:]...)
+	cmd.Dir = filepath.Dir(s.Path)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// findSequence finds a sequence of an entry from it's outputs.
+// It returns environs of the entry as well.
+func (a *App) findSequence(path, seq string) (*Sequence, []string, error) {
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputDir := getEnv("OUTPUT_DIR", env)
+	if outputDir == "" {
+		return nil, nil, fmt.Errorf("no output directory information: check OUTPUT_DIR environ")
+	}
+	outputDir = evalEnvString(outputDir, env)
+	if !strings.HasPrefix(seq, outputDir+"/") {
+		return nil, nil, fmt.Errorf("sequence is not in the output directory: %s", seq)
+	}
+	outputs, err := a.ListOutputs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, o := range outputs {
+		for _, v := range o.Versions {
+			for _, vs := range v.Sequences {
+				if vs.Path == seq {
+					return vs, env, nil
+				}
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("sequence not found: %s", seq)
+}
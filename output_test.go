@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReFrame(t *testing.T) {
+	cases := []struct {
+		name string
+		// want is prefix, frame and extension, or nil when it doesn't have a frame.
+		want []string
+	}{
+		{name: "beauty.1001.exr", want: []string{"beauty.", "1001", ".exr"}},
+		{name: "beauty_0001.exr", want: []string{"beauty_", "0001", ".exr"}},
+		{name: "beauty.1.exr", want: []string{"beauty.", "1", ".exr"}},
+		{name: "sh010_v001.1001.exr", want: []string{"sh010_v001.", "1001", ".exr"}},
+		{name: "beauty.exr", want: nil},
+		{name: "beauty1001.exr", want: nil},
+		{name: "beauty.1001", want: nil},
+		{name: "beauty.1001.tar.gz", want: nil},
+	}
+	for _, c := range cases {
+		m := reFrame.FindStringSubmatch(c.name)
+		var got []string
+		if m != nil {
+			got = m[1:]
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("reFrame.FindStringSubmatch(%q): got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestGroupSequences(t *testing.T) {
+	cases := []struct {
+		label string
+		files []string
+		want  []Sequence
+	}{
+		{
+			label: "padded",
+			files: []string{"a.0001.exr", "a.0002.exr", "a.0003.exr"},
+			want: []Sequence{
+				{Path: "a.####.exr", Frames: 3, Padding: 4, Start: 1, End: 3, Missing: []FrameRange{}, First: "a.0001.exr"},
+			},
+		},
+		{
+			label: "unpadded",
+			files: []string{"c.998.exr", "c.999.exr", "c.1000.exr", "c.1001.exr"},
+			want: []Sequence{
+				{Path: "c.#.exr", Frames: 4, Padding: 0, Start: 998, End: 1001, Missing: []FrameRange{}, First: "c.998.exr"},
+			},
+		},
+		{
+			label: "gap",
+			files: []string{"a.0001.exr", "a.0003.exr", "a.0004.exr", "a.0008.exr"},
+			want: []Sequence{
+				{Path: "a.####.exr", Frames: 4, Padding: 4, Start: 1, End: 8, Missing: []FrameRange{{2, 2}, {5, 7}}, MissingFrames: 4, First: "a.0001.exr"},
+			},
+		},
+		{
+			label: "stray frame",
+			files: []string{"a.0001.exr", "a.9999999.exr"},
+			want: []Sequence{
+				{Path: "a.#.exr", Frames: 2, Padding: 0, Start: 1, End: 9999999, Missing: []FrameRange{{2, 9999998}}, MissingFrames: 9999997, First: "a.0001.exr"},
+			},
+		},
+		{
+			label: "non-sequence",
+			files: []string{"a.exr", "b.abc", "a.0001.exr"},
+			want: []Sequence{
+				{Path: "a.####.exr", Frames: 1, Padding: 4, Start: 1, End: 1, Missing: []FrameRange{}, First: "a.0001.exr"},
+				{Path: "a.exr", Missing: []FrameRange{}, First: "a.exr"},
+				{Path: "b.abc", Missing: []FrameRange{}, First: "b.abc"},
+			},
+		},
+		{
+			label: "different extensions",
+			files: []string{"a.0001.exr", "a.0001.png"},
+			want: []Sequence{
+				{Path: "a.####.exr", Frames: 1, Padding: 4, Start: 1, End: 1, Missing: []FrameRange{}, First: "a.0001.exr"},
+				{Path: "a.####.png", Frames: 1, Padding: 4, Start: 1, End: 1, Missing: []FrameRange{}, First: "a.0001.png"},
+			},
+		},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		files := make([]string, 0, len(c.files))
+		for _, f := range c.files {
+			path := filepath.Join(dir, f)
+			err := os.WriteFile(path, []byte("x"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, path)
+		}
+		seqs := groupSequences(files)
+		if len(seqs) != len(c.want) {
+			t.Errorf("%s: got %d sequences, want %d", c.label, len(seqs), len(c.want))
+			continue
+		}
+		for i, s := range seqs {
+			want := c.want[i]
+			want.Path = filepath.Join(dir, want.Path)
+			want.First = filepath.Join(dir, want.First)
+			// each file has a byte.
+			want.Size = 1
+			if want.Frames != 0 {
+				want.Size = int64(want.Frames)
+			}
+			// ModTime is not a concern of this test.
+			got := *s
+			got.ModTime = want.ModTime
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: got %+v, want %+v", c.label, got, want)
+			}
+		}
+	}
+}
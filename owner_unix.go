@@ -0,0 +1,42 @@
+//go:build !windows
+
+package main
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var (
+	// hold ownerLock before modify ownerName
+	ownerLock sync.Mutex
+	// ownerName caches user names by uid, as looking up a user could be slow. (ex: LDAP)
+	ownerName = make(map[uint32]string)
+)
+
+// fileOwner returns name of the user who owns the file.
+// It returns the uid if it cannot find the user name, or an empty string if it cannot find the owner.
+func fileOwner(fi fs.FileInfo) string {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	ownerLock.Lock()
+	name, ok := ownerName[st.Uid]
+	ownerLock.Unlock()
+	if ok {
+		return name
+	}
+	name = strconv.FormatUint(uint64(st.Uid), 10)
+	u, err := user.LookupId(name)
+	if err == nil {
+		name = u.Username
+	}
+	ownerLock.Lock()
+	ownerName[st.Uid] = name
+	ownerLock.Unlock()
+	return name
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package main
+
+import (
+	"io/fs"
+)
+
+// fileOwner returns name of the user who owns the file.
+// Windows doesn't keep the owner in file info, so it always returns an empty string.
+func fileOwner(fi fs.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+)
+
+// PathMap is a path prefix that points to the same place on each os.
+// Keys are names of the os those are the same as GOOS. (ex: linux, windows, darwin)
+type PathMap map[string]string
+
+// ResolvePath maps a path written for another os, to the path for this os.
+// It returns the path as is, when it doesn't match any path map.
+func (a *App) ResolvePath(path string) string {
+	return mapPath(a.config.PathMap, runtime.GOOS, path)
+}
+
+// defaultPathEnvs are environs the app uses as paths.
+// They are mapped with path maps, in addition to Config.PathEnvs.
+var defaultPathEnvs = []string{"SCENE_DIR", "OUTPUT_DIR", "PUBLISH_DIR", "ENTRY_TEMPLATE_ROOT"}
+
+// resolveEnv maps value of an environ with path maps, only when the environ is a path.
+// Other environs, like lists of paths or queries, would be broken by mapping.
+func (a *App) resolveEnv(name, val string) string {
+	return mapEnv(a.config.PathMap, runtime.GOOS, a.config.PathEnvs, name, val)
+}
+
+// mapEnv is mapPath for an environ, that maps the value only when name is in
+// defaultPathEnvs or pathEnvs.
+func mapEnv(maps []PathMap, goos string, pathEnvs []string, name, val string) string {
+	for _, env := range append(defaultPathEnvs, pathEnvs...) {
+		if env == name {
+			return mapPath(maps, goos, val)
+		}
+	}
+	return val
+}
+
+// mapPath maps a path with prefix of other os in the path maps, to the prefix for goos.
+// Paths are compared with forward slashes, and case-insensitively for windows prefixes.
+func mapPath(maps []PathMap, goos, path string) string {
+	p := strings.ReplaceAll(path, `\`, "/")
+	for _, m := range maps {
+		dst, ok := m[goos]
+		if !ok {
+			continue
+		}
+		for os, src := range m {
+			if os == goos {
+				continue
+			}
+			src = strings.TrimSuffix(strings.ReplaceAll(src, `\`, "/"), "/")
+			if src == "" {
+				continue
+			}
+			if !hasPathPrefix(p, src, os == "windows") {
+				continue
+			}
+			dst = strings.TrimSuffix(dst, "/")
+			if goos == "windows" {
+				dst = strings.TrimSuffix(dst, `\`)
+				// same as filepath.FromSlash on windows, goos could be different from the running os.
+				return strings.ReplaceAll(dst+p[len(src):], "/", `\`)
+			}
+			return dst + p[len(src):]
+		}
+	}
+	return path
+}
+
+// hasPathPrefix reports whether the path is the prefix or under the prefix.
+func hasPathPrefix(path, prefix string, fold bool) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	head := path[:len(prefix)]
+	if fold {
+		if !strings.EqualFold(head, prefix) {
+			return false
+		}
+	} else if head != prefix {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
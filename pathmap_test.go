@@ -0,0 +1,70 @@
+package main
+
+import "testing"
+
+func TestMapPath(t *testing.T) {
+	maps := []PathMap{
+		{"linux": "/mnt/show", "windows": `Z:\show`, "darwin": "/Volumes/show"},
+		{"linux": "/mnt/showroom", "windows": `Y:\`},
+	}
+	cases := []struct {
+		goos string
+		path string
+		want string
+	}{
+		{goos: "linux", path: `Z:\show\a\b.blend`, want: "/mnt/show/a/b.blend"},
+		{goos: "linux", path: `z:\SHOW\a\b.blend`, want: "/mnt/show/a/b.blend"},
+		{goos: "linux", path: "Z:/show/a", want: "/mnt/show/a"},
+		{goos: "linux", path: `Z:\show`, want: "/mnt/show"},
+		{goos: "linux", path: "/Volumes/show/a", want: "/mnt/show/a"},
+		// darwin prefixes are case-sensitive.
+		{goos: "linux", path: "/volumes/show/a", want: "/volumes/show/a"},
+		{goos: "linux", path: `Y:\a`, want: "/mnt/showroom/a"},
+		{goos: "linux", path: `Z:\showroom\a`, want: `Z:\showroom\a`},
+		{goos: "windows", path: "/mnt/show/a/b.blend", want: `Z:\show\a\b.blend`},
+		{goos: "windows", path: "/mnt/showroom/a", want: `Y:\a`},
+		{goos: "windows", path: "/mnt/show", want: `Z:\show`},
+		{goos: "windows", path: "/mnt/showreel/a", want: "/mnt/showreel/a"},
+		{goos: "darwin", path: `Z:\show\a`, want: "/Volumes/show/a"},
+		{goos: "darwin", path: "/mnt/showroom/a", want: "/mnt/showroom/a"},
+	}
+	for _, c := range cases {
+		got := mapPath(maps, c.goos, c.path)
+		if got != c.want {
+			t.Errorf("mapPath(%s, %q): got %q, want %q", c.goos, c.path, got, c.want)
+		}
+		// mapping a mapped path again shouldn't change it.
+		again := mapPath(maps, c.goos, got)
+		if again != got {
+			t.Errorf("mapPath(%s, %q) is not idempotent: got %q, then %q", c.goos, c.path, got, again)
+		}
+	}
+}
+
+func TestMapEnv(t *testing.T) {
+	maps := []PathMap{
+		{"linux": "/mnt/show", "windows": `S:\show`},
+	}
+	pathEnvs := []string{"SHOW_ROOT"}
+	cases := []struct {
+		goos string
+		name string
+		val  string
+		want string
+	}{
+		{goos: "windows", name: "SCENE_DIR", val: "/mnt/show/a/scenes", want: `S:\show\a\scenes`},
+		{goos: "windows", name: "SHOW_ROOT", val: "/mnt/show", want: `S:\show`},
+		{goos: "linux", name: "OUTPUT_DIR", val: `S:\show\a\render`, want: "/mnt/show/a/render"},
+		// values those aren't paths are kept, even if they contain paths.
+		{goos: "windows", name: "SEARCH_PATH", val: "/mnt/show/a:/mnt/show/b", want: "/mnt/show/a:/mnt/show/b"},
+		{goos: "linux", name: "SEARCH_PATH", val: `S:\show\a;S:\show\b`, want: `S:\show\a;S:\show\b`},
+		{goos: "windows", name: "SCENE_NAME_QUERY", val: `/mnt/show/(?P<ELEM>\w+)\.blend`, want: `/mnt/show/(?P<ELEM>\w+)\.blend`},
+		{goos: "windows", name: "REZ_PACKAGES", val: "blender-3 /mnt/show/pkg", want: "blender-3 /mnt/show/pkg"},
+	}
+	for _, c := range cases {
+		got := mapEnv(maps, c.goos, pathEnvs, c.name, c.val)
+		if got != c.want {
+			t.Errorf("mapEnv(%s, %s=%q): got %q, want %q", c.goos, c.name, c.val, got, c.want)
+		}
+	}
+}
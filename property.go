@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PossibleStatus returns status names defined in possible_status global of the entry type.
+func (a *App) PossibleStatus(entType string) []string {
+	possibleStatus := a.Global(entType, "possible_status")
+	if possibleStatus == nil {
+		return []string{}
+	}
+	names := make([]string, 0)
+	stats := strings.Split(possibleStatus.Value, " ")
+	for _, s := range stats {
+		toks := strings.Split(s, ":")
+		name := strings.TrimSpace(toks[0])
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// UpdateProperty updates a property of an entry in the host.
+// It refreshes the current entry and its sub entries afterwards,
+// so the change is shown immediately.
+func (a *App) UpdateProperty(path, name, value string) error {
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return err
+	}
+	p := ent.Property[name]
+	if p == nil {
+		return fmt.Errorf("property not exists: %v.%v", path, name)
+	}
+	value, err = checkPropertyValue(p.Type, value)
+	if err != nil {
+		return fmt.Errorf("%v.%v: %v", path, name, err)
+	}
+	if name == "status" && value != "" {
+		possible := a.PossibleStatus(ent.Type)
+		if len(possible) != 0 {
+			ok := false
+			for _, s := range possible {
+				if s == value {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return fmt.Errorf("invalid status for %v: %v", ent.Type, value)
+			}
+		}
+	}
+	err = updateProperty(a.host, a.session, path, name, value)
+	if err != nil {
+		return err
+	}
+	if p.Type == "user" {
+		// assignment could be changed.
+		err = a.ReloadAssigned()
+		if err != nil {
+			return err
+		}
+	}
+	return a.refreshEntries(path)
+}
+
+// checkPropertyValue checks the value before sending it to the host,
+// so obvious mistakes could be reported without a round trip.
+// The host validates the value again.
+func checkPropertyValue(typ, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		// unset
+		return "", nil
+	}
+	switch typ {
+	case "int":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("not an int: %v", value)
+		}
+		return strconv.Itoa(n), nil
+	case "date":
+		// html date input gives yyyy-mm-dd, while forge wants yyyy/mm/dd.
+		date := strings.ReplaceAll(value, "-", "/")
+		toks := strings.Split(date, "/")
+		if len(toks) != 3 || len(toks[0]) != 4 || len(toks[1]) != 2 || len(toks[2]) != 2 {
+			return "", fmt.Errorf("invalid date: want yyyy/mm/dd, got %v", value)
+		}
+		return date, nil
+	case "user":
+		if strings.ContainsAny(value, " \t\n") {
+			return "", fmt.Errorf("invalid user: %v", value)
+		}
+		return value, nil
+	}
+	return value, nil
+}
+
+// refreshEntries reloads the current entry and its sub entries,
+// if the updated entry is one of them.
+func (a *App) refreshEntries(path string) error {
+	if a.state.Entry == nil {
+		return nil
+	}
+	cur := a.state.Path
+	if path != cur && filepath.ToSlash(filepath.Dir(path)) != cur {
+		return nil
+	}
+	if path == cur {
+		ent, err := a.GetEntry(cur)
+		if err != nil {
+			return err
+		}
+		a.state.Entry = ent
+	}
+	if a.state.AtLeaf {
+		return nil
+	}
+	ents, err := a.ListEntries(cur)
+	if err != nil {
+		return err
+	}
+	a.state.Entries = ents
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestCheckPropertyValue(t *testing.T) {
+	cases := []struct {
+		typ     string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		// an empty value unsets the property, whatever the type is.
+		{typ: "int", value: "  ", want: ""},
+		{typ: "int", value: " 42 ", want: "42"},
+		{typ: "int", value: "-7", want: "-7"},
+		{typ: "int", value: "007", want: "7"},
+		{typ: "int", value: "4.2", wantErr: true},
+		{typ: "int", value: "forty", wantErr: true},
+		{typ: "date", value: "2024/03/09", want: "2024/03/09"},
+		{typ: "date", value: "2024-03-09", want: "2024/03/09"},
+		{typ: "date", value: "2024/3/9", wantErr: true},
+		{typ: "date", value: "24/03/09", wantErr: true},
+		{typ: "date", value: "2024/03", wantErr: true},
+		{typ: "user", value: " kim ", want: "kim"},
+		{typ: "user", value: "kim@imagvfx.com", want: "kim@imagvfx.com"},
+		{typ: "user", value: "kim lee", wantErr: true},
+		{typ: "text", value: " first line\nsecond line ", want: "first line\nsecond line"},
+		{typ: "entry_path", value: "/show/shot", want: "/show/shot"},
+	}
+	for _, c := range cases {
+		got, err := checkPropertyValue(c.typ, c.value)
+		if c.wantErr {
+			if err == nil {
+				t.Fatalf("%s %q: want error, got %q", c.typ, c.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s %q: %v", c.typ, c.value, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s %q: got %q, want %q", c.typ, c.value, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultPublishProperty is the entry property where publish information will be recorded,
+// when PUBLISH_PROPERTY environ is not defined.
+const defaultPublishProperty = "publish"
+
+// publishEnvironPrefix is the prefix of record environs those keep publish history.
+const publishEnvironPrefix = "CANAL_PUBLISH_"
+
+// PublishRecord is a record of a publish. Every publish of an entry is kept as a record.
+type PublishRecord struct {
+	ID       int
+	Elem     string
+	Program  string
+	Version  string
+	File     string
+	Checksum string
+	User     string
+	At       time.Time
+	Comment  string
+}
+
+// Publish publishes a version of an element to forge.
+// It adds a record to the publish history of the part entry,
+// and shows the last publish on the entry's property defined by PUBLISH_PROPERTY environ.
+// When PUBLISH_DIR environ is defined, the scene will be copied into the directory,
+// and the copied file will be recorded instead.
+// A version could be published again, with the same copy if it was copied before.
+// Versions with integrity problems cannot be published.
+func (a *App) Publish(path, elem, ver, prog, comment string) error {
+	pg := a.Program(prog)
+	if pg == nil {
+		return fmt.Errorf("unknown program: %s", prog)
+	}
+	v, _, err := a.findVersion(path, elem, ver, prog)
+	if err != nil {
+		return err
+	}
+	if len(v.Problems) != 0 {
+		return fmt.Errorf("cannot publish %s: %s", ver, strings.Join(v.Problems, ", "))
+	}
+	// checksum is taken before anything, so it is of the version that the user decided to publish.
+	sum := ""
+	if !pg.DirScene {
+		sum, err = fileChecksum(v.Scene)
+		if err != nil {
+			return err
+		}
+	}
+	env, err := a.EntryEnvirons(path)
+	if err != nil {
+		return err
+	}
+	published := v.Scene
+	copied := false
+	publishDir := getEnv("PUBLISH_DIR", env)
+	if publishDir != "" {
+		publishDir = evalEnvString(publishDir, env)
+		err = os.MkdirAll(publishDir, 0755)
+		if err != nil {
+			return err
+		}
+		published = publishDir + "/" + filepath.Base(v.Scene)
+		copied, err = copyToPublish(v, published, sum)
+		if err != nil {
+			return err
+		}
+	}
+	rec := &PublishRecord{
+		Elem:     elem,
+		Program:  prog,
+		Version:  ver,
+		File:     published,
+		Checksum: sum,
+		User:     a.user,
+		At:       time.Now().UTC().Truncate(time.Second),
+		Comment:  comment,
+	}
+	err = a.addPublishRecord(path, rec)
+	if err != nil {
+		if copied {
+			os.RemoveAll(published)
+		}
+		return fmt.Errorf("record publish: %v", err)
+	}
+	err = updateSceneMeta(v.Scene, func(meta *SceneMeta) {
+		meta.PublishedBy = a.user
+		meta.PublishedAt = rec.At
+		meta.PublishedFile = published
+		meta.PublishedChecksum = sum
+		meta.PublishComment = comment
+	})
+	if err != nil {
+		return err
+	}
+	prop := getEnv("PUBLISH_PROPERTY", env)
+	if prop == "" {
+		prop = defaultPublishProperty
+	}
+	value := fmt.Sprintf("elem: %s\nprogram: %s\nversion: %s\nfile: %s\nuser: %s", elem, prog, ver, published, a.user)
+	if comment != "" {
+		value += "\n\n" + comment
+	}
+	err = updateProperty(a.host, a.session, path, prop, value)
+	if err != nil {
+		// the publish is recorded in the history already.
+		return fmt.Errorf("update publish property: %v", err)
+	}
+	return nil
+}
+
+// addPublishRecord adds a publish record to the history of the entry, with the next id.
+func (a *App) addPublishRecord(path string, rec *PublishRecord) error {
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return err
+	}
+	recs, err := a.PublishHistory(path)
+	if err != nil {
+		return err
+	}
+	id := 1
+	if len(recs) != 0 {
+		id = recs[0].ID + 1
+	}
+	_, err = a.addRecordEnviron(publishEnvironPrefix, path, ent.ID, id, func(id int) (string, error) {
+		rec.ID = id
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	})
+	return err
+}
+
+// PublishHistory returns publish records of the entry, from the latest.
+func (a *App) PublishHistory(path string) ([]*PublishRecord, error) {
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return nil, err
+	}
+	forgeEnv, err := entryEnvirons(a.host, a.session, path)
+	if err != nil {
+		return nil, err
+	}
+	recs := make([]*PublishRecord, 0)
+	for _, e := range forgeEnv {
+		entID, id, ok := parseRecordEnvironName(publishEnvironPrefix, e.Name)
+		if !ok || entID != ent.ID {
+			// records of parents are inherited.
+			continue
+		}
+		rec := &PublishRecord{}
+		err := json.Unmarshal([]byte(e.Value), rec)
+		if err != nil {
+			log.Printf("invalid publish record %s: %v", e.Name, err)
+			continue
+		}
+		// the environ name is the source of truth.
+		rec.ID = id
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].ID > recs[j].ID
+	})
+	return recs, nil
+}
+
+// copyToPublish copies a version to the publish path, and checks the copy with sum if it isn't empty.
+// When the version was published to the path before, the existing copy is used again if it's not changed.
+// It returns true when it has copied the version.
+func copyToPublish(v Version, published, sum string) (bool, error) {
+	_, err := os.Stat(published)
+	if err == nil {
+		// re-publishing the same version.
+		if v.meta == nil || v.meta.PublishedFile != published {
+			return false, fmt.Errorf("file exists in publish directory: %s", published)
+		}
+		if sum != "" {
+			psum, err := fileChecksum(published)
+			if err != nil {
+				return false, err
+			}
+			if psum != sum {
+				return false, fmt.Errorf("published file is different from %s: %s", v.Name, published)
+			}
+		}
+		return false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	err = copyScene(v.Scene, published)
+	if err != nil {
+		return false, fmt.Errorf("copy to publish directory: %v", err)
+	}
+	if sum != "" {
+		psum, err := fileChecksum(published)
+		if err != nil {
+			os.RemoveAll(published)
+			return false, err
+		}
+		if psum != sum {
+			os.RemoveAll(published)
+			return false, fmt.Errorf("%s is changed while publishing", v.Name)
+		}
+	}
+	return true, nil
+}
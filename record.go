@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Records, like notes and publishes, are kept in environs of an entry, one environ per record,
+// so adding a record never rewrites others. They are named <prefix><entry id>_<record id>.
+// The entry id is in the name, as environs of parents are inherited by name.
+
+// recordEnvironPrefixes are prefixes of environs those keep records.
+// They are not passed to programs.
+var recordEnvironPrefixes = []string{noteEnvironPrefix, publishEnvironPrefix}
+
+// addRecordRetry is how many ids addRecordEnviron tries when they are taken by others.
+const addRecordRetry = 5
+
+// isRecordEnviron returns true if the environ keeps a record.
+func isRecordEnviron(name string) bool {
+	for _, prefix := range recordEnvironPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEnvironName returns name of the environ that keeps a record of an entry.
+func recordEnvironName(prefix string, entID, id int) string {
+	return fmt.Sprintf("%s%d_%d", prefix, entID, id)
+}
+
+// parseRecordEnvironName parses entry id and record id from a record environ name.
+// It returns false if the name isn't a record environ name with the prefix.
+func parseRecordEnvironName(prefix, name string) (int, int, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return 0, 0, false
+	}
+	ent, id, ok := strings.Cut(strings.TrimPrefix(name, prefix), "_")
+	if !ok {
+		return 0, 0, false
+	}
+	entID, err := strconv.Atoi(ent)
+	if err != nil {
+		return 0, 0, false
+	}
+	recID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, 0, false
+	}
+	return entID, recID, true
+}
+
+// addRecordEnviron adds a record to the entry, trying ids from id.
+// forge doesn't allow environs with the same name in an entry,
+// so only one of the records added at the same time takes an id. Others take the next one.
+// value formats the record with the id it takes. It returns the id.
+func (a *App) addRecordEnviron(prefix, path string, entID, id int, value func(id int) (string, error)) (int, error) {
+	for i := 0; i < addRecordRetry; i++ {
+		val, err := value(id + i)
+		if err != nil {
+			return 0, err
+		}
+		name := recordEnvironName(prefix, entID, id+i)
+		err = addEnviron(a.host, a.session, path, name, "text", val)
+		if err == nil {
+			return id + i, nil
+		}
+		_, gerr := getEnviron(a.host, a.session, path, name)
+		if gerr != nil {
+			// the id wasn't taken by others, something else went wrong.
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("others are adding at the same time, try again")
+}
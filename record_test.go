@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestRecordEnvironName(t *testing.T) {
+	for _, prefix := range recordEnvironPrefixes {
+		name := recordEnvironName(prefix, 12, 3)
+		if !isRecordEnviron(name) {
+			t.Errorf("isRecordEnviron(%q): got false", name)
+		}
+		entID, id, ok := parseRecordEnvironName(prefix, name)
+		if !ok || entID != 12 || id != 3 {
+			t.Errorf("parseRecordEnvironName(%q): got %d, %d, %v", name, entID, id, ok)
+		}
+		for _, name := range []string{"SCENE_DIR", prefix, prefix + "12", prefix + "a_3", prefix + "12_b"} {
+			_, _, ok := parseRecordEnvironName(prefix, name)
+			if ok {
+				t.Errorf("parseRecordEnvironName(%q): should fail", name)
+			}
+		}
+	}
+	if isRecordEnviron("SCENE_DIR") {
+		t.Error("SCENE_DIR is not a record environ")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sceneFile is a file or directory in a scene directory, which could be a scene.
+type sceneFile struct {
+	// Name is a slash separated path relative to the scene directory.
+	Name  string
+	IsDir bool
+}
+
+// sceneDepth returns how deep scenes are placed under the scene directory,
+// by counting path separators in SCENE_NAME and MAIN_SCENE_NAME environs.
+// (ex: ${ELEM}/${UNIT}_${ELEM}_${VER}.${EXT} has depth 1)
+// SCENE_NAME_QUERY is not used, as '/' in a regular expression isn't always a separator. (ex: [^/]+)
+func sceneDepth(env []string) int {
+	depth := 0
+	for _, key := range []string{"SCENE_NAME", "MAIN_SCENE_NAME"} {
+		d := pathDepth(evalEnvString(getEnv(key, env), env))
+		if d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// pathDepth returns the number of path separators in a slash separated name.
+func pathDepth(name string) int {
+	return strings.Count(name, "/")
+}
+
+// listSceneFiles lists files and directories in a scene directory, down to depth.
+// Hidden files and directories like '.canal' are skipped.
+// It returns an error wrapping os.ErrNotExist when the scene directory doesn't exist.
+func listSceneFiles(sceneDir string, depth int) ([]sceneFile, error) {
+	files := make([]sceneFile, 0)
+	var walk func(rel string, d int) error
+	walk = func(rel string, d int) error {
+		ents, err := os.ReadDir(filepath.Join(sceneDir, filepath.FromSlash(rel)))
+		if err != nil {
+			if rel != "" {
+				// the sub directory might be removed in the mean time.
+				return nil
+			}
+			return err
+		}
+		for _, ent := range ents {
+			name := ent.Name()
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			if rel != "" {
+				name = rel + "/" + name
+			}
+			isDir := ent.IsDir()
+			files = append(files, sceneFile{Name: name, IsDir: isDir})
+			if isDir && d < depth {
+				err := walk(name, d+1)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	err := walk("", 0)
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// copyScene copies a scene file, or a scene directory recursively, from src to dst.
+// It fails if dst already exists, to not overwrite others' work.
+func copyScene(src, dst string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return copyFile(src, dst)
+	}
+	err = os.Mkdir(dst, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	err = filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == src {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		to := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			return os.Mkdir(to, fi.Mode().Perm())
+		}
+		return copyFile(p, to)
+	})
+	if err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return nil
+}
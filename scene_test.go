@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestSceneDepth(t *testing.T) {
+	cases := []struct {
+		env  []string
+		want int
+	}{
+		{env: []string{"SCENE_NAME=${UNIT}_${ELEM}_${VER}.${EXT}"}, want: 0},
+		{env: []string{"SCENE_NAME=${ELEM}/${UNIT}_${ELEM}_${VER}.${EXT}"}, want: 1},
+		{env: []string{"SCENE_NAME=${UNIT}_${ELEM}_${VER}.${EXT}", "MAIN_SCENE_NAME=main/${UNIT}_${VER}.${EXT}"}, want: 1},
+		{env: []string{"SCENE_NAME=${ELEM_DIR}/${ELEM}_${VER}.${EXT}", "ELEM_DIR=scenes/${ELEM}"}, want: 2},
+		// slashes in the query are not counted.
+		{env: []string{"SCENE_NAME=${UNIT}_${ELEM}_${VER}.${EXT}", "SCENE_NAME_QUERY=(?P<ELEM>[^/]+)_(?P<VER>v\\d+)[.](?P<EXT>\\w+)"}, want: 0},
+	}
+	for _, c := range cases {
+		got := sceneDepth(c.env)
+		if got != c.want {
+			t.Errorf("sceneDepth(%q): got %d, want %d", c.env, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"container/list"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// thumbCacheSaveDelay is how long the cache waits before saving the index file after a change.
+// Changes in the mean time are saved together, as thumbnails are usually fetched in bulk.
+const thumbCacheSaveDelay = 2 * time.Second
+
+// thumbCache is a size bounded disk cache of thumbnails, keyed by entry path.
+// Least recently used thumbnails are evicted when the cache grows over maxSize.
+// A thumbnail older than maxAge is still returned, but it should be checked with the host.
+// A nil thumbCache is valid, it doesn't cache anything.
+type thumbCache struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+	// hold lock before access fields below
+	lock  sync.Mutex
+	size  int64
+	items map[string]*list.Element
+	lru   *list.List
+	// saveTimer is set while saving the index file is scheduled.
+	saveTimer *time.Timer
+}
+
+// thumbCacheItem is information of a cached thumbnail.
+// It is saved to the index file, so the cache survives restarts.
+type thumbCacheItem struct {
+	Path string
+	File string
+	// Sum is md5 checksum of the data, to tell whether a downloaded thumbnail is changed.
+	Sum       string
+	Size      int64
+	FetchedAt time.Time
+	UsedAt    time.Time
+}
+
+// newThumbCache opens a thumbnail cache in the directory.
+// It creates the directory when it doesn't exist.
+func newThumbCache(dir string, maxSize int64, maxAge time.Duration) (*thumbCache, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	c := &thumbCache{
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	err = c.load()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *thumbCache) indexFile() string {
+	return filepath.Join(c.dir, "index.json")
+}
+
+// load loads the index file, and removes files those are not in the index.
+func (c *thumbCache) load() error {
+	items := make([]*thumbCacheItem, 0)
+	data, err := os.ReadFile(c.indexFile())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) != 0 {
+		err = json.Unmarshal(data, &items)
+		if err != nil {
+			// broken index; start over.
+			items = items[:0]
+		}
+	}
+	// the index is saved in most recently used order.
+	known := make(map[string]bool)
+	for _, it := range items {
+		fi, err := os.Stat(filepath.Join(c.dir, it.File))
+		if err != nil {
+			continue
+		}
+		it.Size = fi.Size()
+		c.items[it.Path] = c.lru.PushBack(it)
+		c.size += it.Size
+		known[it.File] = true
+	}
+	ents, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range ents {
+		if e.Name() == "index.json" || known[e.Name()] {
+			continue
+		}
+		os.Remove(filepath.Join(c.dir, e.Name()))
+	}
+	c.evict()
+	return nil
+}
+
+// save saves the index file. Caller should hold c.lock.
+func (c *thumbCache) save() error {
+	items := make([]*thumbCacheItem, 0, c.lru.Len())
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		items = append(items, e.Value.(*thumbCacheItem))
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	tmp := c.indexFile() + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.indexFile())
+}
+
+// evict removes least recently used thumbnails until the cache fits in maxSize.
+// Caller should hold c.lock.
+func (c *thumbCache) evict() {
+	for c.size > c.maxSize && c.lru.Len() > 1 {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// removeElement removes a thumbnail. Caller should hold c.lock.
+func (c *thumbCache) removeElement(e *list.Element) {
+	it := e.Value.(*thumbCacheItem)
+	os.Remove(filepath.Join(c.dir, it.File))
+	c.lru.Remove(e)
+	delete(c.items, it.Path)
+	c.size -= it.Size
+}
+
+// Get returns cached thumbnail data of the entry.
+// fresh is false when the thumbnail is older than maxAge.
+func (c *thumbCache) Get(path string) (data []byte, fresh bool, ok bool) {
+	if c == nil {
+		return nil, false, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e := c.items[path]
+	if e == nil {
+		return nil, false, false
+	}
+	it := e.Value.(*thumbCacheItem)
+	data, err := os.ReadFile(filepath.Join(c.dir, it.File))
+	if err != nil {
+		c.removeElement(e)
+		return nil, false, false
+	}
+	it.UsedAt = time.Now()
+	c.lru.MoveToFront(e)
+	fresh = time.Since(it.FetchedAt) < c.maxAge
+	return data, fresh, true
+}
+
+// Sum returns checksum of the cached thumbnail of the entry.
+// It returns an empty string when the entry's thumbnail isn't cached.
+func (c *thumbCache) Sum(path string) string {
+	if c == nil {
+		return ""
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e := c.items[path]
+	if e == nil {
+		return ""
+	}
+	return e.Value.(*thumbCacheItem).Sum
+}
+
+// Touch marks the cached thumbnail of the entry as fresh, after it is checked with the host.
+// It returns false when the entry's thumbnail isn't cached.
+func (c *thumbCache) Touch(path string) bool {
+	if c == nil {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e := c.items[path]
+	if e == nil {
+		return false
+	}
+	it := e.Value.(*thumbCacheItem)
+	it.FetchedAt = time.Now()
+	c.scheduleSave()
+	return true
+}
+
+// Put caches thumbnail data of the entry.
+// It returns true when the data is different from the previously cached one.
+func (c *thumbCache) Put(path string, data []byte) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	md5sum := md5.Sum(data)
+	sum := base64.URLEncoding.EncodeToString(md5sum[:])
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	if e := c.items[path]; e != nil {
+		it := e.Value.(*thumbCacheItem)
+		if it.Sum == sum {
+			it.FetchedAt = now
+			it.UsedAt = now
+			c.lru.MoveToFront(e)
+			c.scheduleSave()
+			return false, nil
+		}
+		c.removeElement(e)
+	}
+	key := sha1.Sum([]byte(path))
+	it := &thumbCacheItem{
+		Path:      path,
+		File:      hex.EncodeToString(key[:]) + ".png",
+		Sum:       sum,
+		Size:      int64(len(data)),
+		FetchedAt: now,
+		UsedAt:    now,
+	}
+	err := os.WriteFile(filepath.Join(c.dir, it.File), data, 0644)
+	if err != nil {
+		return false, err
+	}
+	c.items[path] = c.lru.PushFront(it)
+	c.size += it.Size
+	c.evict()
+	c.scheduleSave()
+	return true, nil
+}
+
+// Remove removes a cached thumbnail of the entry.
+func (c *thumbCache) Remove(path string) error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e := c.items[path]
+	if e == nil {
+		return nil
+	}
+	c.removeElement(e)
+	c.scheduleSave()
+	return nil
+}
+
+// scheduleSave saves the index file after thumbCacheSaveDelay, unless it is already scheduled.
+// Caller should hold c.lock.
+func (c *thumbCache) scheduleSave() {
+	if c.saveTimer != nil {
+		return
+	}
+	c.saveTimer = time.AfterFunc(thumbCacheSaveDelay, func() {
+		err := c.Flush()
+		if err != nil {
+			log.Printf("save thumbnail cache: %v", err)
+		}
+	})
+}
+
+// Flush saves the index file now, if there are changes not saved yet.
+func (c *thumbCache) Flush() error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.saveTimer == nil {
+		return nil
+	}
+	c.saveTimer.Stop()
+	c.saveTimer = nil
+	return c.save()
+}
+
+// flightGroup runs a function only once at a time for a key,
+// callers with the same key wait and share the result.
+type flightGroup struct {
+	lock  sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// Do runs fn, or waits for the running fn of the key to finish.
+func (g *flightGroup) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.lock.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &flightCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.lock.Unlock()
+
+	c.val, c.err = fn()
+	c.wg.Done()
+
+	g.lock.Lock()
+	delete(g.calls, key)
+	g.lock.Unlock()
+	return c.val, c.err
+}
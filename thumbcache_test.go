@@ -0,0 +1,211 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestThumbCacheFlush(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newThumbCache(dir, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := c.Put("/show/shot", []byte("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("new thumbnail should be changed")
+	}
+	changed, err = c.Put("/show/shot", []byte("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Fatal("same thumbnail shouldn't be changed")
+	}
+	// the index is saved later.
+	_, err = os.Stat(c.indexFile())
+	if !os.IsNotExist(err) {
+		t.Fatalf("index saved before flush: %v", err)
+	}
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = newThumbCache(dir, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, fresh, ok := c.Get("/show/shot")
+	if !ok || !fresh || string(data) != "thumb" {
+		t.Fatalf("got %q, fresh %v, ok %v after reopen", data, fresh, ok)
+	}
+}
+
+func TestNilThumbCache(t *testing.T) {
+	var c *thumbCache
+	changed, err := c.Put("/show/shot", []byte("thumb"))
+	if err != nil || !changed {
+		t.Fatalf("Put: got %v, %v", changed, err)
+	}
+	_, _, ok := c.Get("/show/shot")
+	if ok {
+		t.Fatal("nil cache shouldn't have a thumbnail")
+	}
+	err = c.Remove("/show/shot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// thumbCachePaths returns paths of the cached thumbnails, from the most recently used.
+func thumbCachePaths(c *thumbCache) []string {
+	paths := make([]string, 0)
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		paths = append(paths, e.Value.(*thumbCacheItem).Path)
+	}
+	return paths
+}
+
+func TestThumbCacheEvict(t *testing.T) {
+	// each thumbnail is 4 bytes, the cache holds 3 of them.
+	c, err := newThumbCache(t.TempDir(), 12, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		_, err := c.Put(p, []byte(p+"__"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// use /a, so /b is the least recently used.
+	_, _, ok := c.Get("/a")
+	if !ok {
+		t.Fatal("/a should be cached")
+	}
+	_, err = c.Put("/d", []byte("/d__"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := thumbCachePaths(c)
+	want := []string{"/d", "/a", "/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if _, _, ok := c.Get("/b"); ok {
+		t.Fatal("/b should be evicted")
+	}
+	if c.size != 12 {
+		t.Fatalf("got size %d, want 12", c.size)
+	}
+	ents, err := os.ReadDir(c.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 3 {
+		t.Fatalf("evicted files are left: %v", ents)
+	}
+}
+
+func TestThumbCacheSizeBound(t *testing.T) {
+	c, err := newThumbCache(t.TempDir(), 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/a", "/b", "/c", "/d"} {
+		_, err := c.Put(p, []byte("1234"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.size > 10 {
+			t.Fatalf("cache size %d is over the bound after put %s", c.size, p)
+		}
+	}
+	// a thumbnail bigger than the cache is kept until the next one comes.
+	_, err = c.Put("/big", make([]byte, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := thumbCachePaths(c)
+	if !reflect.DeepEqual(got, []string{"/big"}) {
+		t.Fatalf("got %v, want only /big", got)
+	}
+}
+
+func TestThumbCacheLoadOrder(t *testing.T) {
+	dir := t.TempDir()
+	c, err := newThumbCache(dir, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		_, err := c.Put(p, []byte(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Get("/a")
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the index is saved from the most recently used.
+	c, err = newThumbCache(dir, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := thumbCachePaths(c)
+	want := []string{"/a", "/c", "/b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// reopening with a smaller size evicts the least recently used ones.
+	c, err = newThumbCache(dir, 4, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = thumbCachePaths(c)
+	want = []string{"/a", "/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestThumbCacheTouch(t *testing.T) {
+	c, err := newThumbCache(t.TempDir(), 1<<20, time.Nanosecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Touch("/a") {
+		t.Fatal("touched a thumbnail not cached")
+	}
+	_, err = c.Put("/a", []byte("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Sum("/a") == "" {
+		t.Fatal("cached thumbnail should have a sum")
+	}
+	time.Sleep(time.Millisecond)
+	_, fresh, _ := c.Get("/a")
+	if fresh {
+		t.Fatal("thumbnail should be stale")
+	}
+	c.maxAge = time.Hour
+	if !c.Touch("/a") {
+		t.Fatal("couldn't touch the cached thumbnail")
+	}
+	_, fresh, _ = c.Get("/a")
+	if !fresh {
+		t.Fatal("touched thumbnail should be fresh")
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imagvfx/forge"
+	wails "github.com/wailsapp/wails/v2/pkg/runtime"
+	"golang.org/x/image/draw"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// Images are resized to fit in the size before uploaded.
+// Forge makes 192x108 thumbnails, it leaves a margin for it's own resizing.
+const (
+	thumbnailUploadWidth  = 384
+	thumbnailUploadHeight = 216
+)
+
+// Defaults of thumbnail cache configs.
+const (
+	defaultThumbnailCacheSize = 256 // MiB
+	defaultThumbnailMaxAge    = time.Hour
+)
+
+// thumbnailMaxAge returns ThumbnailMaxAge of the config, or the default when it isn't set.
+func thumbnailMaxAge(cfg *Config) (time.Duration, error) {
+	if cfg.ThumbnailMaxAge == "" {
+		return defaultThumbnailMaxAge, nil
+	}
+	d, err := time.ParseDuration(cfg.ThumbnailMaxAge)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ThumbnailMaxAge: %v", err)
+	}
+	return d, nil
+}
+
+// openThumbCache opens the thumbnail disk cache for the host under the user cache directory.
+// It uses the temp directory instead when the user cache directory isn't available.
+// It returns nil when the cache couldn't be opened, thumbnails will be downloaded every time then.
+func openThumbCache(cfg *Config) *thumbCache {
+	size := int64(defaultThumbnailCacheSize)
+	if cfg.ThumbnailCacheSize > 0 {
+		size = int64(cfg.ThumbnailCacheSize)
+	}
+	maxAge, err := thumbnailMaxAge(cfg)
+	if err != nil {
+		// mustReadConfig should have checked it already.
+		log.Print(err)
+		maxAge = defaultThumbnailMaxAge
+	}
+	cached, err := os.UserCacheDir()
+	if err != nil {
+		cached = os.TempDir()
+	}
+	dir := filepath.Join(cached, "canal", "thumbnail", cfg.Host)
+	c, err := newThumbCache(dir, size<<20, maxAge)
+	if err != nil {
+		log.Printf("couldn't open thumbnail cache, thumbnails will not be cached on disk: %v", err)
+		return nil
+	}
+	return c
+}
+
+// GetThumbnail gets thumbnail of an entry.
+// An entry without a thumbnail shows the nearest parent's thumbnail.
+// Cached thumbnail is returned without waiting the host even if it is old,
+// the old one is checked with the host in background and "thumbnailChanged" event
+// will be emitted with the path of the entry that has the thumbnail, when it is changed.
+func (a *App) GetThumbnail(path string) (*forge.Thumbnail, error) {
+	owner, err := a.thumbnailOwnerOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, fresh, ok := a.thumbCache.Get(owner)
+	if ok {
+		if !fresh {
+			go func() {
+				_, changed, err := a.fetchThumbnail(owner)
+				if err != nil {
+					log.Printf("refresh thumbnail: %v", err)
+					return
+				}
+				if changed {
+					wails.EventsEmit(a.ctx, "thumbnailChanged", owner)
+				}
+			}()
+		}
+		return &forge.Thumbnail{EntryPath: owner, Data: data}, nil
+	}
+	data, _, err = a.fetchThumbnail(owner)
+	if err != nil {
+		return nil, err
+	}
+	return &forge.Thumbnail{EntryPath: owner, Data: data}, nil
+}
+
+// fetchThumbnail downloads thumbnail of an entry and caches it.
+// It returns true when the thumbnail is different from the cached one.
+// A cached thumbnail is downloaded again only when it is changed in the host.
+// Concurrent calls for an entry share a download.
+func (a *App) fetchThumbnail(path string) ([]byte, bool, error) {
+	type fetched struct {
+		data    []byte
+		changed bool
+	}
+	v, err := a.thumbFlight.Do("thumbnail:"+path, func() (interface{}, error) {
+		var data []byte
+		if sum := a.thumbCache.Sum(path); sum != "" {
+			var changed bool
+			var err error
+			data, changed, err = getThumbnailIfChanged(a.host, a.session, path, sum)
+			if err != nil {
+				return nil, err
+			}
+			if !changed && a.thumbCache.Touch(path) {
+				cached, _, ok := a.thumbCache.Get(path)
+				if ok {
+					return &fetched{data: cached, changed: false}, nil
+				}
+			}
+			// data is nil if the cached one has gone in the mean time.
+		}
+		if data == nil {
+			thumb, err := getThumbnail(a.host, a.session, path)
+			if err != nil {
+				return nil, err
+			}
+			data = thumb.Data
+		}
+		changed, err := a.thumbCache.Put(path, data)
+		if err != nil {
+			// the thumbnail is still usable.
+			log.Printf("cache thumbnail: %v", err)
+			changed = true
+		}
+		return &fetched{data: data, changed: changed}, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	f := v.(*fetched)
+	return f.data, f.changed, nil
+}
+
+// noteThumbnails remembers whether the entries have their own thumbnails.
+// Entries are loaded anyway to show the page, remembering them lets the app
+// find thumbnail owners without asking the host for each entry.
+func (a *App) noteThumbnails(ents ...*forge.Entry) {
+	a.thumbnailLock.Lock()
+	defer a.thumbnailLock.Unlock()
+	for _, e := range ents {
+		has, ok := a.hasThumbnail[e.Path]
+		if ok && has == e.HasThumbnail {
+			continue
+		}
+		a.hasThumbnail[e.Path] = e.HasThumbnail
+		if ok {
+			// owners might be changed.
+			a.thumbnailOwner = make(map[string]string)
+		}
+	}
+}
+
+// resolveThumbnailOwner finds thumbnail owner of an entry from remembered entries.
+// It returns false if there is an entry on the way that isn't remembered.
+// The owner will be empty when neither the entry nor it's parents have a thumbnail.
+// Caller should hold a.thumbnailLock.
+func (a *App) resolveThumbnailOwner(path string) (string, bool) {
+	if owner, ok := a.thumbnailOwner[path]; ok {
+		return owner, true
+	}
+	has, ok := a.hasThumbnail[path]
+	if !ok {
+		return "", false
+	}
+	owner := ""
+	if has {
+		owner = path
+	} else if path != "/" {
+		owner, ok = a.resolveThumbnailOwner(filepath.ToSlash(filepath.Dir(path)))
+		if !ok {
+			return "", false
+		}
+	}
+	// siblings will share the parent's result.
+	a.thumbnailOwner[path] = owner
+	return owner, true
+}
+
+// thumbnailOwnerOf returns path of the entry which has the thumbnail for an entry.
+// It is the entry itself or it's nearest parent those has a thumbnail.
+// When it cannot be found from remembered entries, it loads the entry's siblings and parents
+// from the host, so the following calls for the siblings don't need to ask again.
+func (a *App) thumbnailOwnerOf(path string) (string, error) {
+	a.thumbnailLock.Lock()
+	owner, ok := a.resolveThumbnailOwner(path)
+	a.thumbnailLock.Unlock()
+	if !ok {
+		parent := filepath.ToSlash(filepath.Dir(path))
+		_, err := a.thumbFlight.Do("owner:"+parent, func() (interface{}, error) {
+			if path != "/" {
+				ents, err := subEntries(a.host, a.session, parent)
+				if err != nil {
+					return nil, err
+				}
+				a.noteThumbnails(ents...)
+			}
+			_, err := a.ParentEntries(path)
+			if err != nil {
+				return nil, err
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return "", err
+		}
+		a.thumbnailLock.Lock()
+		owner, ok = a.resolveThumbnailOwner(path)
+		a.thumbnailLock.Unlock()
+		if !ok {
+			// the entry wasn't in the listing, ex) archived entry
+			_, err := a.GetEntry(path)
+			if err != nil {
+				return "", err
+			}
+			a.thumbnailLock.Lock()
+			owner, ok = a.resolveThumbnailOwner(path)
+			a.thumbnailLock.Unlock()
+		}
+	}
+	if !ok || owner == "" {
+		return "", fmt.Errorf("couldn't find thumbnail: %v", path)
+	}
+	return owner, nil
+}
+
+// prefetchThumbnails gets thumbnails of the entries concurrently,
+// so they are ready or on the way when the frontend asks for them.
+func (a *App) prefetchThumbnails(paths []string) {
+	const workers = 8
+	ch := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range ch {
+				// entries without thumbnails are common, ignore the error.
+				a.GetThumbnail(p)
+			}
+		}()
+	}
+	for _, p := range paths {
+		ch <- p
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// UploadThumbnailFromFile asks the user to choose an image file,
+// then uploads it as the thumbnail of the entry.
+// It returns false when the user canceled it.
+func (a *App) UploadThumbnailFromFile(path string) (bool, error) {
+	file, err := wails.OpenFileDialog(a.ctx, wails.OpenDialogOptions{
+		Title: "Choose a thumbnail for " + path,
+		Filters: []wails.FileFilter{
+			{DisplayName: "Images", Pattern: "*" + strings.Join(decodableImageExts, ";*")},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	if file == "" {
+		return false, nil
+	}
+	img, err := decodeImageFile(file)
+	if err != nil {
+		return false, err
+	}
+	err = a.uploadThumbnail(path, img)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UploadThumbnailData uploads base64 encoded image data as the thumbnail of the entry.
+// It is used for images in the clipboard, which only the frontend can access.
+func (a *App) UploadThumbnailData(path, data string) error {
+	// data url is also accepted. (ex: data:image/png;base64,...)
+	if strings.HasPrefix(data, "data:") {
+		_, data, _ = strings.Cut(data, ",")
+	}
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return fmt.Errorf("decode image data: %v", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("decode image: %v", err)
+	}
+	return a.uploadThumbnail(path, img)
+}
+
+// UploadThumbnailFromSequence uploads the first frame of a sequence as the thumbnail of the entry.
+func (a *App) UploadThumbnailFromSequence(path, seq string) error {
+	s, _, err := a.findSequence(path, seq)
+	if err != nil {
+		return err
+	}
+	img, err := a.decodeThumbnailSource(s.First)
+	if err != nil {
+		return err
+	}
+	return a.uploadThumbnail(path, img)
+}
+
+// decodableImageExts are extensions of images those the app can decode.
+var decodableImageExts = []string{".png", ".jpg", ".jpeg", ".gif", ".bmp", ".tif", ".tiff", ".webp"}
+
+// canDecodeImage returns whether the app can decode the image file by it's extension.
+func canDecodeImage(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, e := range decodableImageExts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// canThumbnail returns whether the file could be used as a thumbnail.
+// Any file could be, when ThumbnailConverter is configured.
+func (a *App) canThumbnail(file string) bool {
+	return canDecodeImage(file) || len(a.config.ThumbnailConverter) != 0
+}
+
+// decodeThumbnailSource decodes an image file for a thumbnail.
+// Images the app cannot decode are converted to png with ThumbnailConverter first.
+func (a *App) decodeThumbnailSource(file string) (image.Image, error) {
+	if canDecodeImage(file) {
+		return decodeImageFile(file)
+	}
+	if len(a.config.ThumbnailConverter) == 0 {
+		return nil, fmt.Errorf("cannot decode %s: check ThumbnailConverter config", filepath.Base(file))
+	}
+	tmp, err := os.MkdirTemp("", "canal-thumbnail-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	out := filepath.Join(tmp, "thumbnail.png")
+	env := os.Environ()
+	env = append(env, "INPUT="+file)
+	env = append(env, "OUTPUT="+out)
+	cmd := make([]string, 0, len(a.config.ThumbnailConverter))
+	for _, c := range a.config.ThumbnailConverter {
+		cmd = append(cmd, evalEnvString(c, env))
+	}
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Env = env
+	b, err := c.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("convert %s: %v: %s", filepath.Base(file), err, strings.TrimSpace(string(b)))
+	}
+	return decodeImageFile(out)
+}
+
+// uploadThumbnail resizes the image and uploads it as the thumbnail of the entry.
+// Cached thumbnails of the entry and it's sub entries will be invalidated,
+// as sub entries might inherit the thumbnail.
+func (a *App) uploadThumbnail(path string, img image.Image) error {
+	ent, err := a.GetEntry(path)
+	if err != nil {
+		return err
+	}
+	img = fitImage(img, thumbnailUploadWidth, thumbnailUploadHeight)
+	buf := &bytes.Buffer{}
+	err = png.Encode(buf, img)
+	if err != nil {
+		return err
+	}
+	if ent.HasThumbnail {
+		err = updateThumbnail(a.host, a.session, path, buf.Bytes())
+	} else {
+		err = addThumbnail(a.host, a.session, path, buf.Bytes())
+	}
+	if err != nil {
+		return fmt.Errorf("upload thumbnail: %v", err)
+	}
+	a.invalidateThumbnail(path)
+	return a.refreshEntries(path)
+}
+
+// invalidateThumbnail removes cached thumbnail of the entry,
+// and forgets thumbnail owners as sub entries might inherit the new thumbnail.
+func (a *App) invalidateThumbnail(path string) {
+	a.thumbnailLock.Lock()
+	a.hasThumbnail[path] = true
+	a.thumbnailOwner = make(map[string]string)
+	a.thumbnailLock.Unlock()
+	err := a.thumbCache.Remove(path)
+	if err != nil {
+		log.Printf("invalidate thumbnail: %v", err)
+	}
+}
+
+// decodeImageFile decodes an image file.
+// Formats those Go cannot decode, like exr, are not supported. See decodeThumbnailSource.
+func decodeImageFile(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %v", file, err)
+	}
+	return img, nil
+}
+
+// fitImage resizes the image to fit in the size while keeping it's aspect ratio.
+// It doesn't enlarge a smaller image.
+func fitImage(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	iw, ih := b.Dx(), b.Dy()
+	if iw <= w && ih <= h {
+		return img
+	}
+	if iw*h > ih*w {
+		h = ih * w / iw
+	} else {
+		w = iw * h / ih
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
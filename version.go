@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultVerFormat is used when neither VER_FORMAT nor NEW_VER environ is defined.
+const defaultVerFormat = "v001"
+
+// VerScheme is a naming scheme of versions. (ex: v001, ver0001, V01_wip)
+type VerScheme struct {
+	// Prefix comes before the version number. (ex: "v", "ver")
+	// It is matched case-insensitively when parsing a version.
+	Prefix string
+	// Digits is the minimum number of digits of a version number.
+	// Shorter numbers will be padded with zeros.
+	Digits int
+	// Suffix comes after the version number. (ex: "_wip")
+	// It is matched case-insensitively when parsing a version.
+	Suffix string
+	// Start is the version number of the first version.
+	Start int
+}
+
+var reVerFormat = regexp.MustCompile(`^(\D*)(\d+)(\D*)$`)
+
+// parseVerScheme parses a version format like "v001" or "ver0001_wip".
+// The digits of the format determine both the number of digits and the first version number.
+func parseVerScheme(format string) (VerScheme, error) {
+	m := reVerFormat.FindStringSubmatch(format)
+	if m == nil {
+		return VerScheme{}, fmt.Errorf("invalid version format: %q", format)
+	}
+	start, err := strconv.Atoi(m[2])
+	if err != nil {
+		return VerScheme{}, fmt.Errorf("invalid version format: %q", format)
+	}
+	s := VerScheme{
+		Prefix: m[1],
+		Digits: len(m[2]),
+		Suffix: m[3],
+		Start:  start,
+	}
+	return s, nil
+}
+
+// verSchemeOf returns version scheme of given environs.
+// VER_FORMAT environ takes priority over NEW_VER, which was used to define the first version only.
+func verSchemeOf(env []string) (VerScheme, error) {
+	format := getEnv("VER_FORMAT", env)
+	if format == "" {
+		format = getEnv("NEW_VER", env)
+	}
+	if format == "" {
+		format = defaultVerFormat
+	}
+	return parseVerScheme(format)
+}
+
+// Format returns version string of a version number.
+func (s VerScheme) Format(n int) string {
+	return fmt.Sprintf("%s%0*d%s", s.Prefix, s.Digits, n, s.Suffix)
+}
+
+// Parse returns version number of a version string.
+// A version that has different number of digits from the scheme's is still valid.
+func (s VerScheme) Parse(ver string) (int, error) {
+	if len(ver) < len(s.Prefix)+len(s.Suffix) {
+		return -1, fmt.Errorf("invalid version: %q", ver)
+	}
+	pre := ver[:len(s.Prefix)]
+	suf := ver[len(ver)-len(s.Suffix):]
+	if !strings.EqualFold(pre, s.Prefix) || !strings.EqualFold(suf, s.Suffix) {
+		return -1, fmt.Errorf("invalid version: %q", ver)
+	}
+	digits := ver[len(s.Prefix) : len(ver)-len(s.Suffix)]
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return -1, fmt.Errorf("invalid version: %q", ver)
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return -1, fmt.Errorf("invalid version: %q", ver)
+	}
+	return n, nil
+}
+
+// Query returns a regular expression that matches versions of the scheme
+// in a named group 'VER'.
+func (s VerScheme) Query() string {
+	q := `(?P<VER>`
+	if s.Prefix != "" {
+		q += `(?i:` + regexp.QuoteMeta(s.Prefix) + `)`
+	}
+	q += `\d+`
+	if s.Suffix != "" {
+		q += `(?i:` + regexp.QuoteMeta(s.Suffix) + `)`
+	}
+	q += `)`
+	return q
+}
+
+// Normalize formats ver with the scheme, when ver is a bare version number like "12".
+// Otherwise it returns ver as is.
+func (s VerScheme) Normalize(ver string) string {
+	if ver == "" || strings.Trim(ver, "0123456789") != "" {
+		return ver
+	}
+	n, err := strconv.Atoi(ver)
+	if err != nil {
+		return ver
+	}
+	return s.Format(n)
+}
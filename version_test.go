@@ -0,0 +1,182 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestParseVerScheme(t *testing.T) {
+	cases := []struct {
+		format string
+		want   VerScheme
+		err    bool
+	}{
+		{format: "v001", want: VerScheme{Prefix: "v", Digits: 3, Start: 1}},
+		{format: "ver0001", want: VerScheme{Prefix: "ver", Digits: 4, Start: 1}},
+		{format: "V01", want: VerScheme{Prefix: "V", Digits: 2, Start: 1}},
+		{format: "v001_wip", want: VerScheme{Prefix: "v", Digits: 3, Suffix: "_wip", Start: 1}},
+		{format: "001", want: VerScheme{Digits: 3, Start: 1}},
+		{format: "v000", want: VerScheme{Prefix: "v", Digits: 3, Start: 0}},
+		{format: "", err: true},
+		{format: "v", err: true},
+		{format: "v1a2", err: true},
+	}
+	for _, c := range cases {
+		got, err := parseVerScheme(c.format)
+		if c.err {
+			if err == nil {
+				t.Errorf("parseVerScheme(%q): want error, got %+v", c.format, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseVerScheme(%q): %v", c.format, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseVerScheme(%q): got %+v, want %+v", c.format, got, c.want)
+		}
+	}
+}
+
+func TestVerScheme(t *testing.T) {
+	cases := []struct {
+		format string
+		ver    string
+		// n is -1 when ver shouldn't be parsed.
+		n int
+		// formatted is the version formatted from n, when it is different from ver.
+		formatted string
+	}{
+		{format: "v001", ver: "v001", n: 1},
+		{format: "v001", ver: "v012", n: 12},
+		{format: "v001", ver: "V012", n: 12, formatted: "v012"},
+		{format: "v001", ver: "v1000", n: 1000},
+		{format: "v001", ver: "v01", n: 1, formatted: "v001"},
+		{format: "v001", ver: "v", n: -1},
+		{format: "v001", ver: "x001", n: -1},
+		{format: "v001", ver: "v001a", n: -1},
+		{format: "v001", ver: "v-01", n: -1},
+		{format: "ver0001", ver: "ver0012", n: 12},
+		{format: "ver0001", ver: "VER0012", n: 12, formatted: "ver0012"},
+		{format: "ver0001", ver: "v0012", n: -1},
+		{format: "V01", ver: "v02", n: 2, formatted: "V02"},
+		{format: "V01", ver: "V100", n: 100},
+		{format: "v001_wip", ver: "v012_wip", n: 12},
+		{format: "v001_wip", ver: "V012_WIP", n: 12, formatted: "v012_wip"},
+		{format: "v001_wip", ver: "v012", n: -1},
+		{format: "v001_wip", ver: "v012_fin", n: -1},
+		{format: "001", ver: "012", n: 12},
+		{format: "001", ver: "1000", n: 1000},
+		{format: "001", ver: "v012", n: -1},
+	}
+	for _, c := range cases {
+		s, err := parseVerScheme(c.format)
+		if err != nil {
+			t.Fatalf("parseVerScheme(%q): %v", c.format, err)
+		}
+		n, err := s.Parse(c.ver)
+		if c.n < 0 {
+			if err == nil {
+				t.Errorf("%s: Parse(%q): want error, got %d", c.format, c.ver, n)
+			}
+		} else if err != nil {
+			t.Errorf("%s: Parse(%q): %v", c.format, c.ver, err)
+		} else if n != c.n {
+			t.Errorf("%s: Parse(%q): got %d, want %d", c.format, c.ver, n, c.n)
+		}
+		re := regexp.MustCompile(`^` + s.Query() + `$`)
+		m := re.FindStringSubmatch(c.ver)
+		if (m != nil) != (c.n >= 0) {
+			t.Errorf("%s: Query() match %q: got %v, want %v", c.format, c.ver, m != nil, c.n >= 0)
+		}
+		if m != nil && m[re.SubexpIndex("VER")] != c.ver {
+			t.Errorf("%s: Query() VER of %q: got %q", c.format, c.ver, m[re.SubexpIndex("VER")])
+		}
+		if c.n < 0 {
+			continue
+		}
+		want := c.formatted
+		if want == "" {
+			want = c.ver
+		}
+		if got := s.Format(c.n); got != want {
+			t.Errorf("%s: Format(%d): got %q, want %q", c.format, c.n, got, want)
+		}
+	}
+}
+
+func TestVerSchemeQueryInName(t *testing.T) {
+	s, err := parseVerScheme("v001_wip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re := regexp.MustCompile(`^(?P<SHOT>\w+?)_(?P<ELEM>\w+?)_` + s.Query() + `\.blend$`)
+	cases := []struct {
+		name string
+		ver  string
+	}{
+		{name: "sh010_fx_v012_wip.blend", ver: "v012_wip"},
+		{name: "sh010_fx_V012_WIP.blend", ver: "V012_WIP"},
+		{name: "sh010_fx_v1000_wip.blend", ver: "v1000_wip"},
+		{name: "sh010_fx_v012.blend", ver: ""},
+	}
+	for _, c := range cases {
+		m := re.FindStringSubmatch(c.name)
+		got := ""
+		if m != nil {
+			got = m[re.SubexpIndex("VER")]
+		}
+		if got != c.ver {
+			t.Errorf("match %q: got VER %q, want %q", c.name, got, c.ver)
+		}
+	}
+}
+
+func TestVerSchemeNormalize(t *testing.T) {
+	cases := []struct {
+		format string
+		ver    string
+		want   string
+	}{
+		{format: "v001", ver: "12", want: "v012"},
+		{format: "v001", ver: "0012", want: "v012"},
+		{format: "v001", ver: "1000", want: "v1000"},
+		{format: "v001", ver: "v012", want: "v012"},
+		{format: "v001", ver: "", want: ""},
+		{format: "ver0001", ver: "3", want: "ver0003"},
+		{format: "V01", ver: "3", want: "V03"},
+		{format: "v001_wip", ver: "3", want: "v003_wip"},
+		{format: "v001_wip", ver: "v003", want: "v003"},
+		{format: "001", ver: "3", want: "003"},
+	}
+	for _, c := range cases {
+		s, err := parseVerScheme(c.format)
+		if err != nil {
+			t.Fatalf("parseVerScheme(%q): %v", c.format, err)
+		}
+		if got := s.Normalize(c.ver); got != c.want {
+			t.Errorf("%s: Normalize(%q): got %q, want %q", c.format, c.ver, got, c.want)
+		}
+	}
+}
+
+func TestVerSchemeOf(t *testing.T) {
+	cases := []struct {
+		env  []string
+		want string
+	}{
+		{env: nil, want: "v001"},
+		{env: []string{"NEW_VER=ver0001"}, want: "ver0001"},
+		{env: []string{"NEW_VER=ver0001", "VER_FORMAT=V01"}, want: "V01"},
+	}
+	for _, c := range cases {
+		s, err := verSchemeOf(c.env)
+		if err != nil {
+			t.Fatalf("verSchemeOf(%v): %v", c.env, err)
+		}
+		if got := s.Format(s.Start); got != c.want {
+			t.Errorf("verSchemeOf(%v): got first version %q, want %q", c.env, got, c.want)
+		}
+	}
+}
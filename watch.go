@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a watcher waits for changes to be settled, before notifying them.
+const watchDebounce = 500 * time.Millisecond
+
+// watchFallbackPollInterval is the poll interval when fsnotify isn't available.
+const watchFallbackPollInterval = 5 * time.Second
+
+// sceneWatcher watches a scene directory, and calls onChange when files in it are changed.
+// It watches the directory with fsnotify, or polls the directory periodically
+// when fsnotify isn't available or doesn't work for the filesystem. (ex: NFS, SMB)
+type sceneWatcher struct {
+	dir string
+	// depth is how deep it should watch sub directories of dir.
+	depth    int
+	onChange func()
+	done     chan struct{}
+	once     sync.Once
+	// parent is the nearest existing parent directory being watched, while dir doesn't exist.
+	// It is empty when dir is being watched.
+	parent string
+}
+
+// watchSceneDir starts to watch a scene directory, and it's sub directories down to depth.
+// It polls the directory every pollInterval if it is positive, instead of using fsnotify.
+// When the directory doesn't exist yet, it watches the nearest existing parent until the directory is created.
+func watchSceneDir(dir string, depth int, pollInterval time.Duration, onChange func()) *sceneWatcher {
+	w := &sceneWatcher{
+		dir:      dir,
+		depth:    depth,
+		onChange: onChange,
+		done:     make(chan struct{}),
+	}
+	if pollInterval <= 0 {
+		fw, err := fsnotify.NewWatcher()
+		if err == nil {
+			err = w.watch(fw)
+			if err == nil {
+				go w.notify(fw)
+				return w
+			}
+			fw.Close()
+		}
+		log.Printf("couldn't watch %s, polling it every %v instead: %v", dir, watchFallbackPollInterval, err)
+		pollInterval = watchFallbackPollInterval
+	}
+	go w.poll(pollInterval)
+	return w
+}
+
+// Close stops watching the directory.
+func (w *sceneWatcher) Close() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+}
+
+// watch adds the directory to fsnotify watcher, or it's nearest existing parent when it doesn't exist.
+func (w *sceneWatcher) watch(fw *fsnotify.Watcher) error {
+	_, err := os.Stat(w.dir)
+	if err == nil {
+		w.parent = ""
+		return w.addDirs(fw)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	parent := nearestExistingDir(w.dir)
+	if parent == "" {
+		return fmt.Errorf("no parent directory exists: %s", w.dir)
+	}
+	err = fw.Add(parent)
+	if err != nil {
+		return err
+	}
+	w.parent = parent
+	return nil
+}
+
+// rewatch watches the directory again, when it or one of it's parents is created or removed.
+// It returns true when the directory is created.
+func (w *sceneWatcher) rewatch(fw *fsnotify.Watcher) bool {
+	waiting := w.parent != ""
+	if waiting {
+		_, err := os.Stat(w.dir)
+		if err != nil && nearestExistingDir(w.dir) == w.parent {
+			// nothing on the way is created yet.
+			return false
+		}
+	}
+	if waiting {
+		// it might be removed already.
+		fw.Remove(w.parent)
+	}
+	err := w.watch(fw)
+	if err != nil {
+		log.Printf("couldn't watch %s: %v", w.dir, err)
+		return false
+	}
+	return waiting && w.parent == ""
+}
+
+// nearestExistingDir returns the nearest parent directory of dir that exists.
+// It returns an empty string if there isn't.
+func nearestExistingDir(dir string) string {
+	for d := filepath.Dir(dir); ; d = filepath.Dir(d) {
+		fi, err := os.Stat(d)
+		if err == nil && fi.IsDir() {
+			return d
+		}
+		if d == filepath.Dir(d) {
+			return ""
+		}
+	}
+}
+
+// addDirs adds the directory and it's sub directories to fsnotify watcher.
+func (w *sceneWatcher) addDirs(fw *fsnotify.Watcher) error {
+	err := fw.Add(w.dir)
+	if err != nil {
+		return err
+	}
+	if w.depth <= 0 {
+		return nil
+	}
+	files, err := listSceneFiles(w.dir, w.depth-1)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !f.IsDir {
+			continue
+		}
+		// sub directories are not critical, they could be removed in the mean time.
+		fw.Add(filepath.Join(w.dir, filepath.FromSlash(f.Name)))
+	}
+	return nil
+}
+
+// notify calls onChange when fsnotify tells changes, after they are settled.
+func (w *sceneWatcher) notify(fw *fsnotify.Watcher) {
+	defer fw.Close()
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return
+			}
+			if w.parent != "" {
+				// waiting for the directory to be created.
+				if w.rewatch(fw) {
+					settled = time.After(watchDebounce)
+				}
+				continue
+			}
+			if ev.Name == w.dir && ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				// wait for the directory to be created again.
+				w.rewatch(fw)
+			}
+			if ev.Op&fsnotify.Create != 0 && w.depth > 0 {
+				// watch newly created sub directories, re-adding existing ones is harmless.
+				w.addDirs(fw)
+			}
+			settled = time.After(watchDebounce)
+		case _, ok := <-fw.Errors:
+			if !ok {
+				return
+			}
+		case <-settled:
+			settled = nil
+			w.onChange()
+		}
+	}
+}
+
+// fileStat is a part of file info that used to find changes of a file when polling.
+type fileStat struct {
+	size    int64
+	modTime time.Time
+}
+
+// poll calls onChange when it finds changes of the directory.
+func (w *sceneWatcher) poll(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	last := w.snapshot()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick.C:
+			cur := w.snapshot()
+			if !sameSnapshot(last, cur) {
+				w.onChange()
+			}
+			last = cur
+		}
+	}
+}
+
+// snapshot returns stat of files in the directory.
+// It returns an empty snapshot when it couldn't read the directory.
+func (w *sceneWatcher) snapshot() map[string]fileStat {
+	snap := make(map[string]fileStat)
+	files, err := listSceneFiles(w.dir, w.depth)
+	if err != nil {
+		// the directory may not exist yet.
+		return snap
+	}
+	for _, f := range files {
+		fi, err := os.Stat(filepath.Join(w.dir, filepath.FromSlash(f.Name)))
+		if err != nil {
+			continue
+		}
+		snap[f.Name] = fileStat{size: fi.Size(), modTime: fi.ModTime()}
+	}
+	return snap
+}
+
+func sameSnapshot(a, b map[string]fileStat) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, sa := range a {
+		sb, ok := b[name]
+		if !ok {
+			return false
+		}
+		if sa.size != sb.size || !sa.modTime.Equal(sb.modTime) {
+			return false
+		}
+	}
+	return true
+}
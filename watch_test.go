@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNearestExistingDir(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		dir  string
+		want string
+	}{
+		{filepath.Join(dir, "a", "b", "c"), filepath.Join(dir, "a", "b")},
+		{filepath.Join(dir, "a", "x", "y"), filepath.Join(dir, "a")},
+		{filepath.Join(dir, "a", "b"), filepath.Join(dir, "a")},
+	}
+	for _, c := range cases {
+		got := nearestExistingDir(c.dir)
+		if got != c.want {
+			t.Fatalf("%s: got %s, want %s", c.dir, got, c.want)
+		}
+	}
+}
+
+func TestWatchSceneDirCreated(t *testing.T) {
+	sceneDir := filepath.Join(t.TempDir(), "scene", "shot")
+	changed := make(chan struct{}, 1)
+	w := watchSceneDir(sceneDir, 1, 0, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	defer w.Close()
+	wait := func(what string) {
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("onChange not called after %s", what)
+		}
+	}
+	// parents are created one by one, the watcher should follow them.
+	err := os.Mkdir(filepath.Dir(sceneDir), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(sceneDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait("the scene directory is created")
+	err = os.WriteFile(filepath.Join(sceneDir, "a.v001.blend"), []byte("a"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait("a scene is created")
+	// it should wait for the directory again, when it's removed.
+	err = os.RemoveAll(sceneDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait("the scene directory is removed")
+	err = os.Mkdir(sceneDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait("the scene directory is created again")
+	err = os.WriteFile(filepath.Join(sceneDir, "b.v001.blend"), []byte("b"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait("a scene is created again")
+}